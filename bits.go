@@ -3,13 +3,19 @@
 Package i64 implements a bit field that consists of a single 64-bit word.
 
 It can therefore efficiently represent an integer set, but only for values
-between 0 and 63, inclusive.
+between 0 and 63, inclusive. For values between 0 and 127, use Bits128, which
+has the same core API.
 
 */
 package i64
 
 import (
+	"errors"
+	"fmt"
+	"iter"
 	"math/bits"
+	"math/rand"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -19,6 +25,8 @@ import (
 // Unless otherwise specified, methods that accept a bit position, such as Set
 // and Test, do not check their arguments, so invoking them with values outside
 // [0, 63] will either corrupt the bit field or return an incorrect answer.
+// When a bit position comes from an untrusted source, use the checked variants
+// SetChecked, UnsetChecked, and TestChecked instead.
 //
 // Bits is defined as a uint64; therefore, it can be copied and compared for
 // equality like any built-in integer value.
@@ -52,6 +60,21 @@ func Range(low, high, step int) Bits {
 	return b
 }
 
+// Full returns a bit field with all 64 bits set.
+//
+// Because a bit field does not track an upper bound, Full sets every position
+// in [0, 63], even if only a smaller range is meaningful to the caller. For
+// example, an allow-list may start as Full() and then remove positions with
+// Unset; its Count will be 64 minus the number of positions removed.
+func Full() Bits {
+	return ^Bits(0)
+}
+
+// Clear returns the empty bit field. It is equivalent to Bits(0).
+func Clear() Bits {
+	return 0
+}
+
 // Set returns a copy of the bit field that has the nth bit set.
 func (b Bits) Set(n int) Bits {
 	return b | (1 << uint64(n))
@@ -62,11 +85,78 @@ func (b Bits) Unset(n int) Bits {
 	return b & ^(1 << uint64(n))
 }
 
+// Flip returns a copy of the bit field that has the nth bit toggled.
+func (b Bits) Flip(n int) Bits {
+	return b ^ (1 << uint64(n))
+}
+
+// FlipRange returns a copy of the bit field with every bit in [low, high]
+// toggled. Any bits outside [0, 63] are ignored, as with Range.
+func (b Bits) FlipRange(low, high int) Bits {
+	return b ^ Range(low, high, 1)
+}
+
+// Mask returns the set bits of the field that lie in [low, high]. Any bits
+// outside [0, 63] are ignored, as with Range; if low > high, returns the
+// empty field.
+func (b Bits) Mask(low, high int) Bits {
+	return b & Range(low, high, 1)
+}
+
+// Slice is like Mask, but shifts the result down so that bit low becomes bit
+// 0, so that a packed sub-field can be compared directly as a small integer.
+// For example, Of(9, 10).Slice(8, 15) returns Bits(6).
+func (b Bits) Slice(low, high int) Bits {
+	if low < 0 {
+		low = 0
+	}
+	return b.Mask(low, high) >> uint64(low)
+}
+
 // Test reports whether the nth bit in the field is set.
 func (b Bits) Test(n int) bool {
 	return b&(1<<uint64(n)) != 0
 }
 
+// ErrOutOfRange is returned, possibly wrapped, by functions that reject a bit
+// position outside [0, 63]. Use errors.Is to test for it.
+var ErrOutOfRange = errors.New("bit position out of range")
+
+// checkRange returns an error wrapping ErrOutOfRange if n is not in [0, 63].
+func checkRange(n int) error {
+	if n < 0 || n > 63 {
+		return fmt.Errorf("i64: %w: %d", ErrOutOfRange, n)
+	}
+	return nil
+}
+
+// SetChecked is like Set, but returns an error wrapping ErrOutOfRange if n is
+// outside [0, 63].
+func (b Bits) SetChecked(n int) (Bits, error) {
+	if err := checkRange(n); err != nil {
+		return b, err
+	}
+	return b.Set(n), nil
+}
+
+// UnsetChecked is like Unset, but returns an error wrapping ErrOutOfRange if n
+// is outside [0, 63].
+func (b Bits) UnsetChecked(n int) (Bits, error) {
+	if err := checkRange(n); err != nil {
+		return b, err
+	}
+	return b.Unset(n), nil
+}
+
+// TestChecked is like Test, but returns an error wrapping ErrOutOfRange if n
+// is outside [0, 63].
+func (b Bits) TestChecked(n int) (bool, error) {
+	if err := checkRange(n); err != nil {
+		return false, err
+	}
+	return b.Test(n), nil
+}
+
 // Empty reports whether the bit field is empty, i.e. has zero bits set.
 func (b Bits) Empty() bool {
 	return b == 0
@@ -100,6 +190,119 @@ func (b Bits) Most() int {
 	return 63 - bits.LeadingZeros64(uint64(b))
 }
 
+// Next returns the least significant set bit in the field at or above
+// position n; if bit n is set, returns n. If there is no such bit, returns -1.
+// If n is negative, Next behaves as if n were 0.
+func (b Bits) Next(n int) int {
+	if n > 63 {
+		return -1
+	}
+	if n < 0 {
+		n = 0
+	}
+	return (b >> uint64(n) << uint64(n)).Least()
+}
+
+// Prev returns the most significant set bit in the field at or below position
+// n; if bit n is set, returns n. If there is no such bit, returns -1. If n is
+// greater than 63, Prev behaves as if n were 63.
+func (b Bits) Prev(n int) int {
+	if n < 0 {
+		return -1
+	}
+	if n > 63 {
+		n = 63
+	}
+	return (b & (2<<uint64(n) - 1)).Most()
+}
+
+// Reverse returns a copy of the bit field with the order of all 64 bits
+// reversed, so that bit n moves to position 63-n.
+func (b Bits) Reverse() Bits {
+	return Bits(bits.Reverse64(uint64(b)))
+}
+
+// Union returns a bit field with the bits that are set in either b or other.
+func (b Bits) Union(other Bits) Bits {
+	return b | other
+}
+
+// Intersect returns a bit field with the bits that are set in both b and
+// other.
+func (b Bits) Intersect(other Bits) Bits {
+	return b & other
+}
+
+// Difference returns a bit field with the bits that are set in b but not in
+// other.
+func (b Bits) Difference(other Bits) Bits {
+	return b &^ other
+}
+
+// SymmetricDifference returns a bit field with the bits that are set in
+// exactly one of b and other.
+func (b Bits) SymmetricDifference(other Bits) Bits {
+	return b ^ other
+}
+
+// Complement returns a copy of the bit field with all 64 bits flipped.
+//
+// Note that a bit field does not track the range of values it is used to
+// represent, so Complement sets every clear bit up to and including bit 63.
+// For example, Of(0, 1).Complement() has 62 bits set, not just those in some
+// smaller "logical" range. To complement within a smaller range, intersect
+// the result with a mask such as Range(0, n, 1).
+func (b Bits) Complement() Bits {
+	return ^b
+}
+
+// Invert returns a copy of the bit field with all 64 bits flipped. It is
+// equivalent to Complement and is subject to the same caveat: every clear bit
+// up to and including bit 63 becomes set, so Full().Invert() is empty and
+// Bits(0).Invert() is Full().
+func (b Bits) Invert() Bits {
+	return ^b
+}
+
+// Subset reports whether every bit that is set in b is also set in other.
+// The empty field is a subset of every field, including itself.
+func (b Bits) Subset(other Bits) bool {
+	return b&^other == 0
+}
+
+// Superset reports whether every bit that is set in other is also set in b.
+func (b Bits) Superset(other Bits) bool {
+	return other.Subset(b)
+}
+
+// Disjoint reports whether b and other have no set bits in common.
+// The empty field is disjoint from every field, including itself.
+func (b Bits) Disjoint(other Bits) bool {
+	return b&other == 0
+}
+
+// Equal reports whether b and other have exactly the same bits set.
+// It is equivalent to b == other.
+func (b Bits) Equal(other Bits) bool {
+	return b == other
+}
+
+// Compare returns -1, 0, or +1 depending on whether b is less than, equal to,
+// or greater than other. Bit fields are ordered by the value of their
+// underlying uint64, not by the number of bits set, so the most significant
+// bit at which two fields differ determines their order; for example,
+// Of(63) sorts after Of(0, 1, 2). The empty field sorts first. Compare is
+// suitable for use with slices.SortFunc.
+func (b Bits) Compare(other Bits) int {
+	switch {
+	case b < other:
+		return -1
+	case b > other:
+		return +1
+	}
+	return 0
+}
+
 // String implements the Stringer interface. It returns a string containing the
 // set bits in the field, in ascending order, separated by spaces. For example,
 // Bits(0).Set(1).Set(3).Set(5).String() returns "1 3 5".
@@ -115,11 +318,86 @@ func (b Bits) String() string {
 	return sb.String()
 }
 
+// Style selects a textual representation of a bit field for Format.
+type Style int
+
+const (
+	// Positions lists each set bit, e.g. "1 2 3 5". It is the format
+	// returned by String.
+	Positions Style = iota
+
+	// Ranges collapses each run of two or more consecutive set bits into
+	// a range, e.g. "1-3 5". A single set bit is printed bare.
+	Ranges
+
+	// Hex prints the field's underlying word in hexadecimal, e.g. "0x2e".
+	Hex
+)
+
+// Format returns a string representation of the bit field in the given
+// style. For example, Of(1, 2, 3, 5, 8, 9).Format(i64.Ranges) returns
+// "1-3 5 8-9". If style is not a known Style, Format returns b.String().
+func (b Bits) Format(style Style) string {
+	switch style {
+	case Ranges:
+		var sb strings.Builder
+		var sep string
+		for _, run := range b.Blocks() {
+			low, high := run.Least(), run.Most()
+			sb.WriteString(sep)
+			sb.WriteString(strconv.Itoa(low))
+			if high > low {
+				sb.WriteByte('-')
+				sb.WriteString(strconv.Itoa(high))
+			}
+			sep = " "
+		}
+		return sb.String()
+	case Hex:
+		return "0x" + strconv.FormatUint(uint64(b), 16)
+	}
+	return b.String()
+}
+
 // Iter returns an iterator over the bits in the field.
 func (b Bits) Iter() Iter {
 	return Iter(b)
 }
 
+// All returns an iterator over the set bits in the field, in ascending order.
+// It yields the same sequence as Iter, but can be used with a range loop:
+//
+//	for x := range b.All() {
+//		fmt.Println(x)
+//	}
+//
+// Like Iter, the sequence is a copy of the field; subsequent changes to the
+// field do not affect it.
+func (b Bits) All() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		it := b.Iter()
+		for x := it.Next(); x >= 0; x = it.Next() {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// ReverseAll returns an iterator over the set bits in the field, in
+// descending order. It is otherwise identical to All.
+func (b Bits) ReverseAll() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for x := uint64(b); x != 0; {
+			n := 63 - bits.LeadingZeros64(x)
+			if !yield(n) {
+				return
+			}
+			x &^= 1 << uint64(n)
+		}
+	}
+}
+
 // Iter iterates over the set bits in a bit field.
 //
 // Example usage:
@@ -149,3 +427,819 @@ func (it *Iter) Next() int {
 	*it = Iter(b & (b - 1))
 	return n
 }
+
+// IncrementField treats the width bits starting at offset as an unsigned
+// integer and increments it, leaving the bits outside that window untouched.
+// It returns the updated field and reports whether the increment wrapped
+// around to zero. The arguments are not checked: width must be in [1, 64] and
+// offset+width must not exceed 64.
+func (b Bits) IncrementField(offset, width int) (Bits, bool) {
+	mask := Bits(1)<<uint64(width) - 1
+	v := (b>>uint64(offset) + 1) & mask
+	return b&^(mask<<uint64(offset)) | v<<uint64(offset), v == 0
+}
+
+// Period returns the smallest positive p such that every bit in the field
+// equals the bit p positions above it, i.e. the pattern of the low bits
+// repeats with period p across the full 64-bit width. If the field has no
+// such period, returns 64.
+func (b Bits) Period() int {
+	for p := 1; p < 64; p++ {
+		if b>>uint64(p) == b&(1<<uint64(64-p)-1) {
+			return p
+		}
+	}
+	return 64
+}
+
+// AdjacentPairs returns the set bits whose next higher neighbor is also set.
+// Each set bit in the result marks the lower member of a pair of consecutive
+// set bits in the field.
+func (b Bits) AdjacentPairs() Bits {
+	return b & (b >> 1)
+}
+
+// Isolated returns the set bits that have no set neighbor on either side,
+// i.e. the runs of set bits whose length is exactly one.
+func (b Bits) Isolated() Bits {
+	return b &^ (b>>1 | b<<1)
+}
+
+// Transpose converts a slice of bit fields into bit-planes: the nth bit of the
+// ith result is set iff the ith bit of bs[n] is set. The result always has 64
+// elements; only the first 64 elements of bs are considered, and any missing
+// inputs are treated as empty. Transposing the result yields the original
+// fields, padded with empty fields to a length of 64.
+func Transpose(bs []Bits) []Bits {
+	if len(bs) > 64 {
+		bs = bs[:64]
+	}
+	planes := make([]Bits, 64)
+	for n, b := range bs {
+		it := b.Iter()
+		for i := it.Next(); i >= 0; i = it.Next() {
+			planes[i] = planes[i].Set(n)
+		}
+	}
+	return planes
+}
+
+// RingPosition returns a position in [0, ringSize) derived from a well-mixed
+// hash of the field, suitable for placing the field on a consistent hashing
+// ring. The result is deterministic for a given field and ring size.
+// ringSize must be positive.
+func (b Bits) RingPosition(ringSize int) int {
+	hi, _ := bits.Mul64(mix64(uint64(b)), uint64(ringSize))
+	return int(hi)
+}
+
+// mix64 is the splitmix64 finalizer; it scrambles x so that every input bit
+// affects every output bit.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Agreement returns the fraction of all 64 positions at which the field and
+// other agree, i.e. are both set or both clear. Identical fields have an
+// agreement of 1, complementary fields an agreement of 0.
+func (b Bits) Agreement(other Bits) float64 {
+	return float64(64-bits.OnesCount64(uint64(b^other))) / 64
+}
+
+// Truncate returns a copy of the bit field with all bits above maxPos cleared,
+// and reports whether any set bits were dropped. If maxPos is negative, the
+// result is empty; if it is 63 or greater, the field is returned unchanged.
+func (b Bits) Truncate(maxPos int) (Bits, bool) {
+	if maxPos >= 63 {
+		return b, false
+	}
+	var keep Bits
+	if maxPos >= 0 {
+		keep = 1<<uint64(maxPos+1) - 1
+	}
+	return b & keep, b&^keep != 0
+}
+
+// SortedBy returns the set bits in the field, ordered by less instead of
+// numerically. less reports whether position a should sort before position b.
+// The sort is stable: positions that compare equal remain in ascending order.
+func (b Bits) SortedBy(less func(a, b int) bool) []int {
+	var xs []int
+	it := b.Iter()
+	for x := it.Next(); x >= 0; x = it.Next() {
+		xs = append(xs, x)
+	}
+	sort.SliceStable(xs, func(i, j int) bool { return less(xs[i], xs[j]) })
+	return xs
+}
+
+// DiffSummary compares the field with other and returns the number of bits
+// that are set only in other (added), set only in the receiver (removed), and
+// set in both (unchangedSet).
+func (b Bits) DiffSummary(other Bits) (added, removed, unchangedSet int) {
+	return (other &^ b).Count(), (b &^ other).Count(), (b & other).Count()
+}
+
+// Thermometer returns a bit field with bits 0 through level-1 set, i.e. the
+// unary (thermometer) encoding of level. level is clamped to [0, 64].
+func Thermometer(level int) Bits {
+	if level <= 0 {
+		return 0
+	}
+	if level >= 64 {
+		return ^Bits(0)
+	}
+	return 1<<uint64(level) - 1
+}
+
+// ThermometerLevel decodes a bit field produced by Thermometer. It returns the
+// encoded level and true, or zero and false if the set bits of b do not form a
+// contiguous run starting at bit 0.
+func ThermometerLevel(b Bits) (int, bool) {
+	if b&(b+1) != 0 {
+		return 0, false
+	}
+	return b.Count(), true
+}
+
+// DriftHistogram counts, for each position, how many of the snapshots differ
+// from baseline at that position.
+func DriftHistogram(baseline Bits, snapshots []Bits) [64]int {
+	var counts [64]int
+	for _, s := range snapshots {
+		it := (s ^ baseline).Iter()
+		for x := it.Next(); x >= 0; x = it.Next() {
+			counts[x]++
+		}
+	}
+	return counts
+}
+
+// NearestWithCount returns a bit field with exactly target bits set that has
+// the minimum Hamming distance from b. target is clamped to [0, 64].
+//
+// Every field that differs from b only by setting clear bits (or only by
+// clearing set bits) is equally close; ties are broken by setting the lowest
+// clear bits when b has too few bits set, and by clearing the lowest set bits
+// when it has too many.
+func (b Bits) NearestWithCount(target int) Bits {
+	if target < 0 {
+		target = 0
+	} else if target > 64 {
+		target = 64
+	}
+	for n := b.Count(); n < target; n++ {
+		b |= ^b & (b + 1) // set lowest clear bit
+	}
+	for n := b.Count(); n > target; n-- {
+		b &= b - 1 // clear lowest set bit
+	}
+	return b
+}
+
+// Offsets returns the signed distance of each set bit from pivot, in ascending
+// order of position. Bits below pivot have negative offsets.
+func (b Bits) Offsets(pivot int) []int {
+	var xs []int
+	it := b.Iter()
+	for x := it.Next(); x >= 0; x = it.Next() {
+		xs = append(xs, x-pivot)
+	}
+	return xs
+}
+
+// Canonical returns a canonical representative of the field's symmetry class
+// under the dihedral group of the 64-bit ring: the numerically smallest field
+// among all rotations of b and all rotations of b with its bit order reversed.
+// Two fields have the same canonical form iff one can be obtained from the
+// other by rotation, reflection, or both.
+func (b Bits) Canonical() Bits {
+	best := b
+	r := b.Reverse()
+	for k := 0; k < 64; k++ {
+		if x := Bits(bits.RotateLeft64(uint64(b), k)); x < best {
+			best = x
+		}
+		if x := Bits(bits.RotateLeft64(uint64(r), k)); x < best {
+			best = x
+		}
+	}
+	return best
+}
+
+// Stretch returns a bit field in which each set bit n of the low 32 bits is
+// moved to position 2n. Bits 32 through 63 are ignored, so
+// b.Stretch().Compress() == b & Range(0, 31, 1). Stretch is useful for
+// interleaving two fields: a.Stretch() | b.Stretch()<<1 places the bits of a
+// at even positions and those of b at odd positions.
+func (b Bits) Stretch() Bits {
+	x := uint64(b) & 0x00000000ffffffff
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return Bits(x)
+}
+
+// Compress returns a bit field in which each set bit at even position 2n is
+// moved to position n. Bits at odd positions are ignored. Compress undoes
+// Stretch for the low 32 bits, but since it discards the odd bits,
+// b.Compress().Stretch() equals b only if b has no odd bits set.
+func (b Bits) Compress() Bits {
+	x := uint64(b) & 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return Bits(x)
+}
+
+// RisingEdges returns the set bits whose next lower neighbor is clear, i.e.
+// the first (least significant) bit of each run of set bits. Bit 0 is a rising
+// edge if it is set.
+func (b Bits) RisingEdges() Bits {
+	return b &^ (b << 1)
+}
+
+// FallingEdges returns the set bits whose next higher neighbor is clear, i.e.
+// the last (most significant) bit of each run of set bits. Bit 63 is a falling
+// edge if it is set.
+func (b Bits) FallingEdges() Bits {
+	return b &^ (b >> 1)
+}
+
+// RunCount returns the number of maximal runs of consecutive set bits in the
+// field.
+func (b Bits) RunCount() int {
+	return b.RisingEdges().Count()
+}
+
+// BuddyBlocks decomposes the set bits of the field into the fewest naturally
+// aligned power-of-two blocks, as used by a buddy allocator. Each element of
+// the result is a [start, size] pair, where size is a power of two and start is
+// a multiple of size. The blocks are disjoint, appear in ascending order, and
+// together cover exactly the set bits. If the field is empty, returns nil.
+func (b Bits) BuddyBlocks() [][2]int {
+	var blocks [][2]int
+	for b != 0 {
+		start := b.Least()
+		size := 64
+		if start != 0 {
+			size = 1 << uint(bits.TrailingZeros(uint(start)))
+		}
+		for {
+			block := (Bits(1)<<uint64(size) - 1) << uint64(start)
+			if b&block == block {
+				b &^= block
+				break
+			}
+			size /= 2
+		}
+		blocks = append(blocks, [2]int{start, size})
+	}
+	return blocks
+}
+
+// Windows returns the values of every window of w consecutive bits in the
+// field: element i of the result holds bits i through i+w-1, shifted down so
+// that bit i becomes bit 0. The result has 65-w elements. If w is outside
+// [1, 64], returns nil.
+func (b Bits) Windows(w int) []uint64 {
+	if w < 1 || w > 64 {
+		return nil
+	}
+	mask := uint64(1)<<uint64(w) - 1
+	xs := make([]uint64, 65-w)
+	for i := range xs {
+		xs[i] = uint64(b>>uint64(i)) & mask
+	}
+	return xs
+}
+
+// IndexPattern returns the lowest offset i such that bits i through
+// i+width-1 of the field equal the low width bits of pattern, or -1 if there
+// is no such offset. If width is outside [1, 64], returns -1.
+func (b Bits) IndexPattern(pattern Bits, width int) int {
+	if width < 1 || width > 64 {
+		return -1
+	}
+	mask := Bits(1)<<uint64(width) - 1
+	pattern &= mask
+	for i := 0; i <= 64-width; i++ {
+		if (b>>uint64(i))&mask == pattern {
+			return i
+		}
+	}
+	return -1
+}
+
+// Gaps64 returns the clear bits that lie strictly between the least and most
+// significant set bits of the field. If the set bits are contiguous, or fewer
+// than two bits are set, returns the empty field.
+func (b Bits) Gaps64() Bits {
+	if b == 0 {
+		return 0
+	}
+	hull := (Bits(1)<<uint64(b.Most()) - 1) &^ (Bits(1)<<uint64(b.Least()) - 1)
+	return hull &^ b
+}
+
+// RingSlots returns a bit field with count consecutive bits set, starting at
+// head and wrapping around from bit 63 to bit 0, as for the occupied slots of
+// a 64-slot ring buffer. head is taken modulo 64, and count is clamped to
+// [0, 64].
+func RingSlots(head, count int) Bits {
+	return Bits(bits.RotateLeft64(uint64(Thermometer(count)), head))
+}
+
+// Equivalence returns a bit field with a bit set at each position where the
+// field and other agree, i.e. are both set or both clear. It is the complement
+// of the symmetric difference of the two fields.
+func (b Bits) Equivalence(other Bits) Bits {
+	return ^(b ^ other)
+}
+
+// FromBools returns a bit field with bit i set for each i such that bs[i] is
+// true. Elements of bs beyond the 64th are ignored.
+func FromBools(bs []bool) Bits {
+	var b Bits
+	for i, v := range bs {
+		if i >= 64 {
+			break
+		}
+		if v {
+			b = b.Set(i)
+		}
+	}
+	return b
+}
+
+// Chunks splits the field into n sub-fields covering disjoint, contiguous
+// ranges of positions, in ascending order. The ranges have as nearly equal
+// sizes as possible: chunk i covers positions [64*i/n, 64*(i+1)/n). The union
+// of the chunks equals b. n is clamped to at most 64; if n is less than 1,
+// returns nil.
+func (b Bits) Chunks(n int) []Bits {
+	if n < 1 {
+		return nil
+	}
+	if n > 64 {
+		n = 64
+	}
+	chunks := make([]Bits, n)
+	for i := range chunks {
+		low, high := 64*i/n, 64*(i+1)/n
+		chunks[i] = b & (Thermometer(high) &^ Thermometer(low))
+	}
+	return chunks
+}
+
+// FirstWithCount returns the numerically smallest bit field with k bits set,
+// i.e. the field with bits 0 through k-1 set. k is clamped to [0, 64].
+func FirstWithCount(k int) Bits {
+	return Thermometer(k)
+}
+
+// LastWithCount returns the numerically largest bit field with k bits set,
+// i.e. the field with bits 64-k through 63 set. k is clamped to [0, 64].
+func LastWithCount(k int) Bits {
+	if k <= 0 {
+		return 0
+	}
+	return ^Thermometer(64 - k)
+}
+
+// Threshold returns a bit field with a bit set at each position that is set in
+// at least quorum of the fields in bs. If quorum is less than 1, every position
+// qualifies and the result is full.
+func Threshold(bs []Bits, quorum int) Bits {
+	var tally [64]int
+	for _, b := range bs {
+		it := b.Iter()
+		for x := it.Next(); x >= 0; x = it.Next() {
+			tally[x]++
+		}
+	}
+	var b Bits
+	for x, n := range tally {
+		if n >= quorum {
+			b = b.Set(x)
+		}
+	}
+	return b
+}
+
+// RankVector returns, for each position i, the number of set bits in the field
+// below position i. Since the entries for set positions are consecutive
+// integers starting at zero, the result maps each set bit to a dense index.
+func (b Bits) RankVector() [64]int {
+	var ranks [64]int
+	n := 0
+	for i := range ranks {
+		ranks[i] = n
+		if b.Test(i) {
+			n++
+		}
+	}
+	return ranks
+}
+
+// OneHotValue returns the position of the set bit and true if exactly one bit
+// in the field is set. Otherwise, it returns zero and false.
+func (b Bits) OneHotValue() (int, bool) {
+	if !b.Singular() {
+		return 0, false
+	}
+	return b.Least(), true
+}
+
+// DumpState returns a verbose, multi-line description of every position in
+// the field, such as "bit 0: set", "bit 1: clear", and so on through bit 63.
+// Each line, including the last, is terminated by a newline.
+func (b Bits) DumpState() string {
+	var sb strings.Builder
+	for i := 0; i < 64; i++ {
+		state := "clear"
+		if b.Test(i) {
+			state = "set"
+		}
+		sb.WriteString("bit ")
+		sb.WriteString(strconv.Itoa(i))
+		sb.WriteString(": ")
+		sb.WriteString(state)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Step computes one generation of a one-dimensional cellular automaton over
+// the field. The new state of each bit n is rule(left, center, right), where
+// center is the current state of bit n, left is the state of bit n+1, and
+// right is the state of bit n-1, so that the field reads like a binary number
+// from left to right. Neighbors outside [0, 63] are treated as clear.
+func (b Bits) Step(rule func(left, center, right bool) bool) Bits {
+	left, right := b>>1, b<<1
+	var next Bits
+	for n := 0; n < 64; n++ {
+		if rule(left.Test(n), b.Test(n), right.Test(n)) {
+			next = next.Set(n)
+		}
+	}
+	return next
+}
+
+// ByteCounts returns the number of set bits in each of the 8 bytes of the
+// field. Element 0 counts bits 0 through 7, element 1 bits 8 through 15, and
+// so on.
+func (b Bits) ByteCounts() [8]int {
+	var counts [8]int
+	for i := range counts {
+		counts[i] = bits.OnesCount8(uint8(b >> uint64(8*i)))
+	}
+	return counts
+}
+
+// SummaryString returns up to limit of the set bits in the field, in ascending
+// order and separated by commas. If more than limit bits are set, the list is
+// followed by a marker giving the number of bits omitted. For example,
+// Of(1, 3, 5, 7).SummaryString(2) returns "1,3…(+2 more)".
+func (b Bits) SummaryString(limit int) string {
+	var sb strings.Builder
+	it := b.Iter()
+	n := 0
+	for x := it.Next(); x >= 0 && n < limit; x = it.Next() {
+		if n > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.Itoa(x))
+		n++
+	}
+	if rest := b.Count() - n; rest > 0 {
+		sb.WriteString("…(+")
+		sb.WriteString(strconv.Itoa(rest))
+		sb.WriteString(" more)")
+	}
+	return sb.String()
+}
+
+// RunLengths returns the run-length encoding of the field: the lengths of the
+// alternating runs of clear and set bits, starting with the run of clear bits
+// at bit 0 (which has length zero if bit 0 is set). The lengths sum to 64.
+func (b Bits) RunLengths() []int {
+	var runs []int
+	for pos, set := 0, false; pos < 64; set = !set {
+		x := uint64(b >> uint64(pos))
+		if set {
+			x = ^x
+		}
+		n := bits.TrailingZeros64(x)
+		if n > 64-pos {
+			n = 64 - pos
+		}
+		runs = append(runs, n)
+		pos += n
+	}
+	return runs
+}
+
+// FromRunLengths returns the bit field described by a run-length encoding, as
+// returned by RunLengths: the lengths of alternating runs of clear and set
+// bits, starting with a run of clear bits at bit 0. Any positions beyond the
+// last run are clear. It returns an error if a length is negative or the
+// lengths sum to more than 64.
+func FromRunLengths(runs []int) (Bits, error) {
+	var b Bits
+	pos := 0
+	for i, n := range runs {
+		if n < 0 {
+			return 0, fmt.Errorf("i64: negative run length %d", n)
+		}
+		if n > 64-pos {
+			return 0, fmt.Errorf("i64: run lengths exceed 64 bits")
+		}
+		if i%2 == 1 {
+			b |= Thermometer(pos+n) &^ Thermometer(pos)
+		}
+		pos += n
+	}
+	return b, nil
+}
+
+// Tick advances a 64-slot timing wheel by the given number of slots. It
+// returns the set bits below position by, which have expired, and the
+// remaining bits shifted down by that many positions. The expired bits are
+// reported at their original positions. by is clamped to [0, 64].
+func (b Bits) Tick(by int) (expired Bits, remaining Bits) {
+	if by <= 0 {
+		return 0, b
+	}
+	if by >= 64 {
+		return b, 0
+	}
+	return b & Thermometer(by), b >> uint64(by)
+}
+
+// CoverBytes returns a bit field with the specified bits set, like Of, but
+// accepts the positions as bytes. Any values greater than 63 are ignored.
+func CoverBytes(vs []byte) Bits {
+	var b Bits
+	for _, v := range vs {
+		if v < 64 {
+			b = b.Set(int(v))
+		}
+	}
+	return b
+}
+
+// SymmetryEquivalent reports whether other can be obtained from the field by
+// rotation, reflection, or both. See Canonical.
+func (b Bits) SymmetryEquivalent(other Bits) bool {
+	return b.Canonical() == other.Canonical()
+}
+
+// RandomClear returns a clear bit in the field chosen uniformly at random
+// using r. If the field has no clear bits, returns -1.
+func (b Bits) RandomClear(r *rand.Rand) int {
+	free := ^b
+	if free == 0 {
+		return -1 // full
+	}
+	for k := r.Intn(free.Count()); k > 0; k-- {
+		free &= free - 1
+	}
+	return free.Least()
+}
+
+// FillLowestGap returns a copy of the bit field with its lowest interior gap
+// filled, i.e. with the lowest clear bit that lies between two set bits set.
+// If the field has no interior gaps, it is returned unchanged. See Gaps64.
+func (b Bits) FillLowestGap() Bits {
+	gaps := b.Gaps64()
+	return b | gaps&-gaps
+}
+
+// Centroid returns the mean of the positions of the set bits in the field and
+// true. If the field is empty, returns zero and false.
+func (b Bits) Centroid() (float64, bool) {
+	if b == 0 {
+		return 0, false // empty
+	}
+	sum := 0
+	it := b.Iter()
+	for x := it.Next(); x >= 0; x = it.Next() {
+		sum += x
+	}
+	return float64(sum) / float64(b.Count()), true
+}
+
+// LongestRun returns the length of the longest run of consecutive set bits in
+// the field, or zero if the field is empty.
+func (b Bits) LongestRun() int {
+	n := 0
+	for ; b != 0; n++ {
+		b &= b >> 1
+	}
+	return n
+}
+
+// LongestCommonRun returns the length of the longest run of consecutive
+// positions that are set in both the field and other.
+func (b Bits) LongestCommonRun(other Bits) int {
+	return (b & other).LongestRun()
+}
+
+// Snapshot is an opaque record of the state of a bit field, for implementing
+// undo and redo. See Bits.Snapshot and Bits.Restore.
+type Snapshot struct {
+	b Bits
+}
+
+// Snapshot returns a record of the bit field's current state.
+func (b Bits) Snapshot() Snapshot {
+	return Snapshot{b}
+}
+
+// Restore returns the bit field recorded in s, discarding the receiver.
+//
+// Example usage:
+//
+//	s := b.Snapshot()
+//	b = b.Set(3).Unset(5)
+//	b = b.Restore(s) // undo
+func (b Bits) Restore(s Snapshot) Bits {
+	return s.b
+}
+
+// ReverseBits8 returns a copy of the bit field with the order of the bits
+// within each of its 8 bytes reversed, so that bit 0 of each byte swaps with
+// bit 7 of that byte, bit 1 with bit 6, and so on. The order of the bytes
+// themselves is unchanged.
+func (b Bits) ReverseBits8() Bits {
+	var r Bits
+	for i := uint64(0); i < 64; i += 8 {
+		r |= Bits(bits.Reverse8(uint8(b>>i))) << i
+	}
+	return r
+}
+
+// ToMap returns a map with a true entry for each set bit in the field.
+func (b Bits) ToMap() map[int]bool {
+	m := make(map[int]bool, b.Count())
+	it := b.Iter()
+	for x := it.Next(); x >= 0; x = it.Next() {
+		m[x] = true
+	}
+	return m
+}
+
+// FromMap returns a bit field with a bit set for each true entry in m.
+// Any entries that are false or outside [0, 63] are ignored.
+func FromMap(m map[int]bool) Bits {
+	var b Bits
+	for n, v := range m {
+		if v && n >= 0 && n < 64 {
+			b = b.Set(n)
+		}
+	}
+	return b
+}
+
+// Gradient returns a bit field with a bit set at each position whose state
+// differs from that of its next lower neighbor, which combines the rising and
+// falling edges of each run: for each run of set bits, the result marks its
+// first bit and the position just past its last bit. Bit 0 is compared with a
+// clear neighbor.
+func (b Bits) Gradient() Bits {
+	return b ^ (b << 1)
+}
+
+// Sanitize splits the bit field into the bits that are set in allowed (clean)
+// and those that are not (dropped). The two results are disjoint, and their
+// union is b.
+func (b Bits) Sanitize(allowed Bits) (clean Bits, dropped Bits) {
+	return b.Intersect(allowed), b.Difference(allowed)
+}
+
+// Update returns a bit field in which the state of each position n in [0, 63]
+// is fn(n, b.Test(n)).
+func (b Bits) Update(fn func(pos int, set bool) bool) Bits {
+	var r Bits
+	for n := range 64 {
+		if fn(n, b.Test(n)) {
+			r = r.Set(n)
+		}
+	}
+	return r
+}
+
+// MoveDistance returns the number of moves needed to transform the field into
+// target, where each move clears one set bit and sets one clear bit, and true.
+// This equals the number of bits that are set in b but not in target. If the
+// two fields have different numbers of bits set, no sequence of moves can
+// transform one into the other, and MoveDistance returns zero and false.
+func (b Bits) MoveDistance(target Bits) (int, bool) {
+	if b.Count() != target.Count() {
+		return 0, false
+	}
+	return b.Difference(target).Count(), true
+}
+
+// LocalMaxima returns the set bits whose value, as computed by the given
+// function, is strictly greater than that of each neighboring set bit, where
+// the neighbors of a set bit are the nearest set bits below and above it.
+// A set bit with no neighbors is a local maximum.
+func (b Bits) LocalMaxima(value func(pos int) float64) Bits {
+	var maxima Bits
+	prev, prevValue := -1, 0.0
+	it := b.Iter()
+	x := it.Next()
+	for x >= 0 {
+		v := value(x)
+		next := it.Next()
+		if (prev < 0 || v > prevValue) && (next < 0 || v > value(next)) {
+			maxima = maxima.Set(x)
+		}
+		prev, prevValue, x = x, v, next
+	}
+	return maxima
+}
+
+// AuditDiff scans a history of bit fields in order and returns, for each
+// position, the index of the version in which it last changed, i.e. the
+// largest i such that versions[i] and versions[i-1] differ at that position.
+// Positions that never change are reported as -1.
+func AuditDiff(versions []Bits) [64]int {
+	var last [64]int
+	for i := range last {
+		last[i] = -1
+	}
+	for i := 1; i < len(versions); i++ {
+		for x := range (versions[i] ^ versions[i-1]).All() {
+			last[x] = i
+		}
+	}
+	return last
+}
+
+// ToSlice returns the set bits in the field, in ascending order.
+// If the field is empty, returns nil.
+func (b Bits) ToSlice() []int {
+	if b == 0 {
+		return nil
+	}
+	xs := make([]int, 0, b.Count())
+	for x := range b.All() {
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+// FromSlice returns a bit field with the bits in xs set. It is equivalent to
+// Of(xs...): duplicates are allowed, and any bits outside [0, 63] are ignored.
+func FromSlice(xs []int) Bits {
+	return Of(xs...)
+}
+
+// Blocks returns one bit field for each maximal run of consecutive set bits
+// in the field, containing just the bits of that run, in ascending order.
+// The blocks are disjoint, and their union is b. If the field is empty,
+// returns nil.
+func (b Bits) Blocks() []Bits {
+	var blocks []Bits
+	for b != 0 {
+		run := b &^ (b + b&-b) // adding the lowest set bit clears its run
+		blocks = append(blocks, run)
+		b &^= run
+	}
+	return blocks
+}
+
+// ByteParities returns a byte whose bit i is the parity of byte i of the
+// field, i.e. is set iff byte i has an odd number of bits set.
+func (b Bits) ByteParities() byte {
+	var p byte
+	for i, n := range b.ByteCounts() {
+		p |= byte(n&1) << uint(i)
+	}
+	return p
+}
+
+// ReflectAbout returns a bit field in which each set bit p of the field is
+// moved to position 2*center-p, mirroring the field about center. Bits whose
+// reflection falls outside [0, 63] are dropped.
+func (b Bits) ReflectAbout(center int) Bits {
+	var r Bits
+	for p := range b.All() {
+		if q := 2*center - p; q >= 0 && q < 64 {
+			r = r.Set(q)
+		}
+	}
+	return r
+}
@@ -0,0 +1,141 @@
+package i64
+
+import (
+	"iter"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Bits128 is a field of 128 bits, consisting of two 64-bit words. Word 0 holds
+// bits 0 through 63, and word 1 holds bits 64 through 127.
+//
+// Bits128 provides the same core methods as Bits, with the same semantics, for
+// positions in [0, 127]. Positions outside that range never panic: Set and
+// Unset ignore them, and Test reports false.
+//
+// Bits128 is defined as an array; therefore, it can be copied and compared for
+// equality like any built-in value.
+type Bits128 [2]uint64
+
+// Of128 returns a 128-bit field with the specified bits set.
+// Any bits outside [0, 127] are ignored.
+func Of128(bits ...int) Bits128 {
+	var b Bits128
+	for _, n := range bits {
+		if n >= 0 && n < 128 {
+			b = b.Set(n)
+		}
+	}
+	return b
+}
+
+// Set returns a copy of the bit field that has the nth bit set.
+func (b Bits128) Set(n int) Bits128 {
+	if uint(n) < 128 {
+		b[n>>6] |= 1 << uint64(n&63)
+	}
+	return b
+}
+
+// Unset returns a copy of the bit field that has the nth bit unset.
+func (b Bits128) Unset(n int) Bits128 {
+	if uint(n) < 128 {
+		b[n>>6] &^= 1 << uint64(n&63)
+	}
+	return b
+}
+
+// Test reports whether the nth bit in the field is set.
+func (b Bits128) Test(n int) bool {
+	return uint(n) < 128 && b[n>>6]&(1<<uint64(n&63)) != 0
+}
+
+// Empty reports whether the bit field is empty, i.e. has zero bits set.
+func (b Bits128) Empty() bool {
+	return b[0] == 0 && b[1] == 0
+}
+
+// Count reports the number of bits in the field that are set.
+func (b Bits128) Count() int {
+	return bits.OnesCount64(b[0]) + bits.OnesCount64(b[1])
+}
+
+// Singular reports whether the bit field has exactly one set bit.
+func (b Bits128) Singular() bool {
+	return b.Count() == 1
+}
+
+// Least returns the least significant set bit in the field.
+// If the field has no set bits, returns -1.
+func (b Bits128) Least() int {
+	if b[0] != 0 {
+		return bits.TrailingZeros64(b[0])
+	}
+	if b[1] != 0 {
+		return 64 + bits.TrailingZeros64(b[1])
+	}
+	return -1 // empty
+}
+
+// Most returns the most significant set bit in the field.
+// If the field has no set bits, returns -1.
+func (b Bits128) Most() int {
+	if b[1] != 0 {
+		return 127 - bits.LeadingZeros64(b[1])
+	}
+	if b[0] != 0 {
+		return 63 - bits.LeadingZeros64(b[0])
+	}
+	return -1 // empty
+}
+
+// String implements the Stringer interface. It returns a string containing the
+// set bits in the field, in ascending order, separated by spaces, in the same
+// format as Bits.String.
+func (b Bits128) String() string {
+	var sb strings.Builder
+	var sep string
+	it := b.Iter()
+	for x := it.Next(); x >= 0; x = it.Next() {
+		sb.WriteString(sep)
+		sb.WriteString(strconv.Itoa(x))
+		sep = " "
+	}
+	return sb.String()
+}
+
+// Iter returns an iterator over the bits in the field.
+func (b Bits128) Iter() Iter128 {
+	return Iter128(b)
+}
+
+// All returns an iterator over the set bits in the field, in ascending order.
+// See Bits.All.
+func (b Bits128) All() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		it := b.Iter()
+		for x := it.Next(); x >= 0; x = it.Next() {
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// Iter128 iterates over the set bits in a 128-bit field, visiting the bits of
+// word 0 and then those of word 1, in ascending order. Its usage is the same
+// as that of Iter.
+type Iter128 [2]uint64
+
+// Next returns the next bit in the field.
+// If the iterator is exhausted, returns -1.
+func (it *Iter128) Next() int {
+	for i, w := range it {
+		if w != 0 {
+			it[i] = w & (w - 1)
+			return 64*i + bits.TrailingZeros64(w)
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+package i64
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBits128(t *testing.T) {
+	var (
+		b Bits128
+
+		// If got != want, fails the test. Assumes got was returned by "method".
+		check = func(method string, got, want interface{}) {
+			t.Helper()
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Bits128(%s).%s returned %v, want %v", b, method, got, want)
+			}
+		}
+
+		// Verifies the string representation of b.
+		checkstring = func(want string) {
+			t.Helper()
+			if s := b.String(); s != want {
+				t.Fatalf("Bits128.String() returned %q, want %q", s, want)
+			}
+		}
+
+		// Verifies the result of iterating over b, both with Iter and All.
+		checkiter = func(want ...int) {
+			t.Helper()
+			var xs, ys []int
+			it := b.Iter()
+			for x := it.Next(); x >= 0; x = it.Next() {
+				xs = append(xs, x)
+			}
+			for y := range b.All() {
+				ys = append(ys, y)
+			}
+			if !reflect.DeepEqual(xs, want) || !reflect.DeepEqual(ys, want) {
+				t.Fatalf("iterating over Bits128(%s) returned %+v and %+v, want %+v", b, xs, ys, want)
+			}
+		}
+	)
+
+	checkstring("")
+	checkiter()
+	check("Count()", b.Count(), 0)
+	check("Singular()", b.Singular(), false)
+	check("Empty()", b.Empty(), true)
+	check("Least()", b.Least(), -1)
+	check("Most()", b.Most(), -1)
+
+	b = b.Set(100)
+	checkiter(100)
+	checkstring("100")
+	check("Count()", b.Count(), 1)
+	check("Singular()", b.Singular(), true)
+	check("Empty()", b.Empty(), false)
+	check("Least()", b.Least(), 100)
+	check("Most()", b.Most(), 100)
+
+	b = b.Set(63).Set(64)
+	checkiter(63, 64, 100)
+	checkstring("63 64 100")
+	check("Count()", b.Count(), 3)
+	check("Singular()", b.Singular(), false)
+	check("Least()", b.Least(), 63)
+	check("Most()", b.Most(), 100)
+	check("Test(63)", b.Test(63), true)
+	check("Test(64)", b.Test(64), true)
+	check("Test(65)", b.Test(65), false)
+
+	b = b.Set(0).Set(127)
+	checkiter(0, 63, 64, 100, 127)
+	checkstring("0 63 64 100 127")
+	check("Count()", b.Count(), 5)
+	check("Least()", b.Least(), 0)
+	check("Most()", b.Most(), 127)
+
+	b = b.Unset(0).Unset(127).Unset(64)
+	checkiter(63, 100)
+	checkstring("63 100")
+	check("Least()", b.Least(), 63)
+	check("Most()", b.Most(), 100)
+
+	b = b.Unset(63).Unset(100)
+	checkiter()
+	check("Empty()", b.Empty(), true)
+
+	if got, want := Of128(127, 3, -1, 128, 64), (Bits128{}).Set(3).Set(64).Set(127); got != want {
+		t.Fatalf("Of128(127, 3, -1, 128, 64) returned %s, want %s", got, want)
+	}
+}
+
+func TestBits128OutOfRange(t *testing.T) {
+	b := Of128(0, 64, 127)
+	for _, n := range []int{-1, -64, 128, 192, 1000} {
+		if got := b.Set(n); got != b {
+			t.Fatalf("Bits128(%s).Set(%d) returned %s, want it unchanged", b, n, got)
+		}
+		if got := b.Unset(n); got != b {
+			t.Fatalf("Bits128(%s).Unset(%d) returned %s, want it unchanged", b, n, got)
+		}
+		if b.Test(n) {
+			t.Fatalf("Bits128(%s).Test(%d) returned true", b, n)
+		}
+	}
+}
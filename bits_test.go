@@ -1,7 +1,14 @@
 package i64
 
 import (
+	"errors"
+	"math/bits"
+	"math/rand"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -91,3 +98,1722 @@ func TestBits(t *testing.T) {
 	check("Least()", b.Least(), 2)
 	check("Most()", b.Most(), 12)
 }
+
+func TestIncrementField(t *testing.T) {
+	tests := []struct {
+		b             Bits
+		offset, width int
+		want          Bits
+		overflow      bool
+	}{
+		{0, 0, 4, Of(0), false},
+		{Of(0), 0, 4, Of(1), false},
+		{Of(0, 1, 2), 0, 4, Of(3), false},
+		{Of(0, 1, 2, 3), 0, 4, 0, true},
+		{Of(0, 1, 2, 3, 4, 63), 0, 4, Of(4, 63), true},
+		{Of(0, 9, 10, 11, 20), 8, 4, Of(0, 8, 9, 10, 11, 20), false},
+		{Of(0, 8, 9, 10, 11, 20), 8, 4, Of(0, 20), true},
+		{Of(1, 62, 63), 62, 2, Of(1), true},
+		{^Bits(0), 0, 64, 0, true},
+	}
+	for _, tt := range tests {
+		got, overflow := tt.b.IncrementField(tt.offset, tt.width)
+		if got != tt.want || overflow != tt.overflow {
+			t.Fatalf("Bits(%s).IncrementField(%d, %d) returned (%s, %v), want (%s, %v)",
+				tt.b, tt.offset, tt.width, got, overflow, tt.want, tt.overflow)
+		}
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want int
+	}{
+		{0, 1},
+		{^Bits(0), 1},
+		{Range(0, 63, 2), 2},
+		{Range(1, 63, 2), 2},
+		{Range(0, 63, 3), 3},
+		{Range(4, 63, 8) | Range(5, 63, 8), 8},
+		{Of(0, 1, 3), 64},
+		{Of(63), 64},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Period(); got != tt.want {
+			t.Fatalf("Bits(%s).Period() returned %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAdjacentPairs(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{Of(1, 2, 3), Of(1, 2)},
+		{Of(0, 2, 4, 63), 0},
+		{Of(5, 6, 10, 62, 63), Of(5, 62)},
+		{^Bits(0), Range(0, 62, 1)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.AdjacentPairs(); got != tt.want {
+			t.Fatalf("Bits(%s).AdjacentPairs() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIsolated(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{Of(0, 2, 3), Of(0)},
+		{Of(0, 2, 4, 63), Of(0, 2, 4, 63)},
+		{Of(1, 2, 3, 7, 62, 63), Of(7)},
+		{^Bits(0), 0},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Isolated(); got != tt.want {
+			t.Fatalf("Bits(%s).Isolated() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTranspose(t *testing.T) {
+	xs := []Bits{Of(0, 5), Of(1), 0, Of(5, 63)}
+	planes := Transpose(xs)
+	if len(planes) != 64 {
+		t.Fatalf("Transpose returned %d planes, want 64", len(planes))
+	}
+	for i, want := range map[int]Bits{0: Of(0), 1: Of(1), 5: Of(0, 3), 63: Of(3), 2: 0} {
+		if planes[i] != want {
+			t.Fatalf("Transpose(%v)[%d] is %s, want %s", xs, i, planes[i], want)
+		}
+	}
+	got := Transpose(planes)
+	want := append(append([]Bits(nil), xs...), make([]Bits, 64-len(xs))...)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Transpose(Transpose(%v)) returned %v, want %v", xs, got, want)
+	}
+
+	// A full 64x64 matrix must be restored exactly.
+	xs = make([]Bits, 64)
+	for i := range xs {
+		xs[i] = Bits(uint64(i)*0x9E3779B97F4A7C15 + 1)
+	}
+	if got := Transpose(Transpose(xs)); !reflect.DeepEqual(got, xs) {
+		t.Fatalf("Transpose(Transpose(%v)) returned %v", xs, got)
+	}
+
+	// Inputs beyond the 64th are ignored.
+	xs = append(xs, ^Bits(0))
+	if got := Transpose(xs); !reflect.DeepEqual(got, Transpose(xs[:64])) {
+		t.Fatalf("Transpose did not ignore the 65th input")
+	}
+}
+
+func TestRingPosition(t *testing.T) {
+	for _, size := range []int{1, 2, 7, 64, 1000, 1 << 30} {
+		seen := make(map[int]bool)
+		for i := 0; i < 64; i++ {
+			b := Of(i, (i*7)%64)
+			p := b.RingPosition(size)
+			if p < 0 || p >= size {
+				t.Fatalf("Bits(%s).RingPosition(%d) returned %d, out of range", b, size, p)
+			}
+			if q := b.RingPosition(size); q != p {
+				t.Fatalf("Bits(%s).RingPosition(%d) returned %d then %d", b, size, p, q)
+			}
+			seen[p] = true
+		}
+		if size >= 1000 && len(seen) < 32 {
+			t.Fatalf("RingPosition(%d) produced only %d distinct positions for 64 fields", size, len(seen))
+		}
+	}
+}
+
+func TestAgreement(t *testing.T) {
+	tests := []struct {
+		a, b Bits
+		want float64
+	}{
+		{0, 0, 1},
+		{Of(1, 5, 63), Of(1, 5, 63), 1},
+		{0, ^Bits(0), 0},
+		{Of(1, 5, 63), ^Of(1, 5, 63), 0},
+		{Of(0), 0, 63.0 / 64},
+		{Range(0, 31, 1), Range(0, 63, 2), 0.5},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Agreement(tt.b); got != tt.want {
+			t.Fatalf("Bits(%s).Agreement(%s) returned %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		b      Bits
+		maxPos int
+		want   Bits
+		lossy  bool
+	}{
+		{0, 10, 0, false},
+		{Of(1, 5, 10), 10, Of(1, 5, 10), false},
+		{Of(1, 5, 11), 10, Of(1, 5), true},
+		{Of(1, 5, 63), 62, Of(1, 5), true},
+		{Of(1, 5, 63), 63, Of(1, 5, 63), false},
+		{Of(1, 5, 63), 100, Of(1, 5, 63), false},
+		{Of(0, 1), 0, Of(0), true},
+		{Of(0), -1, 0, true},
+		{0, -1, 0, false},
+	}
+	for _, tt := range tests {
+		got, lossy := tt.b.Truncate(tt.maxPos)
+		if got != tt.want || lossy != tt.lossy {
+			t.Fatalf("Bits(%s).Truncate(%d) returned (%s, %v), want (%s, %v)",
+				tt.b, tt.maxPos, got, lossy, tt.want, tt.lossy)
+		}
+	}
+}
+
+func TestSortedBy(t *testing.T) {
+	b := Of(0, 2, 3, 9, 63)
+	desc := func(a, b int) bool { return a > b }
+	if got, want := b.SortedBy(desc), []int{63, 9, 3, 2, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bits(%s).SortedBy(desc) returned %v, want %v", b, got, want)
+	}
+
+	names := map[int]string{0: "read", 2: "write", 3: "admin", 9: "exec", 63: "debug"}
+	byName := func(a, b int) bool { return names[a] < names[b] }
+	if got, want := b.SortedBy(byName), []int{3, 63, 9, 0, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bits(%s).SortedBy(byName) returned %v, want %v", b, got, want)
+	}
+
+	// Positions with equal keys keep their ascending order.
+	b = Range(0, 63, 1)
+	byParity := func(a, b int) bool { return a%2 < b%2 }
+	want := append(Range(0, 63, 2).ToSlice(), Range(1, 63, 2).ToSlice()...)
+	for i := 0; i < 10; i++ {
+		if got := b.SortedBy(byParity); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Bits(%s).SortedBy(byParity) returned %v, want %v", b, got, want)
+		}
+	}
+
+	if got := Bits(0).SortedBy(desc); len(got) != 0 {
+		t.Fatalf("Bits(0).SortedBy(desc) returned %v, want empty", got)
+	}
+}
+
+func TestDiffSummary(t *testing.T) {
+	tests := []struct {
+		a, b                      Bits
+		added, removed, unchanged int
+	}{
+		{0, 0, 0, 0, 0},
+		{0, Of(1, 2), 2, 0, 0},
+		{Of(1, 2), 0, 0, 2, 0},
+		{Of(1, 2, 3), Of(1, 2, 3), 0, 0, 3},
+		{Of(0, 1, 2, 63), Of(2, 3, 4, 63), 2, 2, 2},
+		{^Bits(0), Of(5), 0, 63, 1},
+	}
+	for _, tt := range tests {
+		added, removed, unchanged := tt.a.DiffSummary(tt.b)
+		if added != tt.added || removed != tt.removed || unchanged != tt.unchanged {
+			t.Fatalf("Bits(%s).DiffSummary(%s) returned (%d, %d, %d), want (%d, %d, %d)",
+				tt.a, tt.b, added, removed, unchanged, tt.added, tt.removed, tt.unchanged)
+		}
+		if added+unchanged != tt.b.Count() || removed+unchanged != tt.a.Count() {
+			t.Fatalf("Bits(%s).DiffSummary(%s) returned inconsistent counts (%d, %d, %d)",
+				tt.a, tt.b, added, removed, unchanged)
+		}
+	}
+}
+
+func TestThermometer(t *testing.T) {
+	for level := 0; level <= 64; level++ {
+		b := Thermometer(level)
+		if b.Count() != level {
+			t.Fatalf("Thermometer(%d) returned %s, want %d bits set", level, b, level)
+		}
+		if got, ok := ThermometerLevel(b); !ok || got != level {
+			t.Fatalf("ThermometerLevel(%s) returned (%d, %v), want (%d, true)", b, got, ok, level)
+		}
+	}
+	if b := Thermometer(-1); b != 0 {
+		t.Fatalf("Thermometer(-1) returned %s, want empty", b)
+	}
+	if b := Thermometer(65); b != ^Bits(0) {
+		t.Fatalf("Thermometer(65) returned %s, want full", b)
+	}
+	for _, b := range []Bits{Of(1), Of(0, 2), Of(0, 1, 63), Of(63)} {
+		if got, ok := ThermometerLevel(b); ok {
+			t.Fatalf("ThermometerLevel(%s) returned (%d, true), want false", b, got)
+		}
+	}
+}
+
+func TestDriftHistogram(t *testing.T) {
+	baseline := Of(0, 1, 63)
+	snapshots := []Bits{
+		Of(0, 1, 63),    // no change
+		Of(0, 63),       // 1 cleared
+		Of(0, 2, 63),    // 1 cleared, 2 set
+		Of(0, 1, 2, 10), // 2 set, 10 set, 63 cleared
+	}
+	var want [64]int
+	want[1] = 2
+	want[2] = 2
+	want[10] = 1
+	want[63] = 1
+	if got := DriftHistogram(baseline, snapshots); got != want {
+		t.Fatalf("DriftHistogram(%s, %v) returned %v, want %v", baseline, snapshots, got, want)
+	}
+	if got := DriftHistogram(baseline, nil); got != [64]int{} {
+		t.Fatalf("DriftHistogram(%s, nil) returned %v, want all zeros", baseline, got)
+	}
+}
+
+func TestNearestWithCount(t *testing.T) {
+	tests := []struct {
+		b      Bits
+		target int
+		want   Bits
+	}{
+		{0, 0, 0},
+		{0, 3, Of(0, 1, 2)},
+		{0, 64, ^Bits(0)},
+		{^Bits(0), 0, 0},
+		{^Bits(0), 64, ^Bits(0)},
+		{^Bits(0), 62, Range(2, 63, 1)},
+		{Of(1, 5, 9), 3, Of(1, 5, 9)},
+		{Of(1, 5, 9), 5, Of(0, 1, 2, 5, 9)},
+		{Of(1, 5, 9), 1, Of(9)},
+		{Of(1, 5, 9), 0, 0},
+		{Of(1, 5, 9), -1, 0},
+		{Of(1, 5, 9), 65, ^Bits(0)},
+	}
+	for _, tt := range tests {
+		got := tt.b.NearestWithCount(tt.target)
+		if got != tt.want {
+			t.Fatalf("Bits(%s).NearestWithCount(%d) returned %s, want %s", tt.b, tt.target, got, tt.want)
+		}
+		n := tt.target
+		if n < 0 {
+			n = 0
+		} else if n > 64 {
+			n = 64
+		}
+		dist := (got ^ tt.b).Count()
+		if want := tt.b.Count() - n; dist != want && dist != -want {
+			t.Fatalf("Bits(%s).NearestWithCount(%d) is at distance %d, want %d", tt.b, tt.target, dist, want)
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	tests := []struct {
+		b     Bits
+		pivot int
+		want  []int
+	}{
+		{0, 3, nil},
+		{Of(1, 5), 3, []int{-2, 2}},
+		{Of(0, 3, 63), 3, []int{-3, 0, 60}},
+		{Of(0, 63), 0, []int{0, 63}},
+		{Of(0, 63), 70, []int{-70, -7}},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Offsets(tt.pivot); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Bits(%s).Offsets(%d) returned %v, want %v", tt.b, tt.pivot, got, tt.want)
+		}
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{^Bits(0), ^Bits(0)},
+		{Of(17), Of(0)},
+		{Of(10, 11), Of(0, 1)},
+		{Of(62, 63, 0), Of(0, 1, 2)},
+		{Of(5, 7, 8), Of(0, 1, 3)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Canonical(); got != tt.want {
+			t.Fatalf("Bits(%s).Canonical() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+
+	b := Of(3, 4, 9, 20, 41)
+	want := b.Canonical()
+	for k := 0; k < 64; k++ {
+		rot := Bits(bits.RotateLeft64(uint64(b), k))
+		ref := Bits(bits.Reverse64(uint64(rot)))
+		if got := rot.Canonical(); got != want {
+			t.Fatalf("Bits(%s).Canonical() returned %s, want %s", rot, got, want)
+		}
+		if got := ref.Canonical(); got != want {
+			t.Fatalf("Bits(%s).Canonical() returned %s, want %s", ref, got, want)
+		}
+	}
+	if got := Of(3, 4, 9, 20, 42).Canonical(); got == want {
+		t.Fatalf("unrelated fields share canonical form %s", got)
+	}
+}
+
+func TestStretch(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{Of(0, 1, 2), Of(0, 2, 4)},
+		{Of(31), Of(62)},
+		{Of(5, 32, 63), Of(10)},
+		{Range(0, 31, 1), Range(0, 63, 2)},
+	}
+	for _, tt := range tests {
+		got := tt.b.Stretch()
+		if got != tt.want {
+			t.Fatalf("Bits(%s).Stretch() returned %s, want %s", tt.b, got, tt.want)
+		}
+		if c := got.Compress(); c != tt.b&Range(0, 31, 1) {
+			t.Fatalf("Bits(%s).Compress() returned %s, want %s", got, c, tt.b&Range(0, 31, 1))
+		}
+	}
+	if got := Range(1, 63, 2).Compress(); got != 0 {
+		t.Fatalf("Compress of odd bits returned %s, want empty", got)
+	}
+	a, b := Of(0, 3, 31), Of(1, 2, 31)
+	if got, want := a.Stretch()|b.Stretch()<<1, Of(0, 3, 5, 6, 62, 63); got != want {
+		t.Fatalf("interleaving %s and %s returned %s, want %s", a, b, got, want)
+	}
+}
+
+func TestEdges(t *testing.T) {
+	tests := []struct {
+		b, rising, falling Bits
+	}{
+		{0, 0, 0},
+		{Of(2, 3, 4), Of(2), Of(4)},
+		{Of(7), Of(7), Of(7)},
+		{Of(0, 1, 5, 8, 9, 10, 63), Of(0, 5, 8, 63), Of(1, 5, 10, 63)},
+		{^Bits(0), Of(0), Of(63)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.RisingEdges(); got != tt.rising {
+			t.Fatalf("Bits(%s).RisingEdges() returned %s, want %s", tt.b, got, tt.rising)
+		}
+		if got := tt.b.FallingEdges(); got != tt.falling {
+			t.Fatalf("Bits(%s).FallingEdges() returned %s, want %s", tt.b, got, tt.falling)
+		}
+	}
+}
+
+func TestRunCount(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want int
+	}{
+		{0, 0},
+		{Of(1, 2, 5, 6, 9), 3},
+		{Of(0, 63), 2},
+		{Range(0, 63, 2), 32},
+		{^Bits(0), 1},
+	}
+	for _, tt := range tests {
+		if got := tt.b.RunCount(); got != tt.want {
+			t.Fatalf("Bits(%s).RunCount() returned %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBuddyBlocks(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want [][2]int
+	}{
+		{0, nil},
+		{^Bits(0), [][2]int{{0, 64}}},
+		{Of(5), [][2]int{{5, 1}}},
+		{Range(0, 7, 1), [][2]int{{0, 8}}},
+		{Range(1, 7, 1), [][2]int{{1, 1}, {2, 2}, {4, 4}}},
+		{Range(3, 12, 1), [][2]int{{3, 1}, {4, 4}, {8, 4}, {12, 1}}},
+		{Range(32, 63, 1).Set(0), [][2]int{{0, 1}, {32, 32}}},
+		{Range(0, 63, 2), nil},
+	}
+	for _, tt := range tests {
+		got := tt.b.BuddyBlocks()
+		if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Bits(%s).BuddyBlocks() returned %v, want %v", tt.b, got, tt.want)
+		}
+		var r Bits
+		for _, blk := range got {
+			start, size := blk[0], blk[1]
+			if size&(size-1) != 0 || start%size != 0 {
+				t.Fatalf("Bits(%s).BuddyBlocks() returned misaligned block %v", tt.b, blk)
+			}
+			block := Range(start, start+size-1, 1)
+			if r&block != 0 {
+				t.Fatalf("Bits(%s).BuddyBlocks() returned overlapping block %v", tt.b, blk)
+			}
+			r |= block
+		}
+		if r != tt.b {
+			t.Fatalf("Bits(%s).BuddyBlocks() returned %v, which covers %s", tt.b, got, r)
+		}
+	}
+	if got := Range(0, 63, 2).BuddyBlocks(); len(got) != 32 {
+		t.Fatalf("BuddyBlocks of even bits returned %d blocks, want 32", len(got))
+	}
+}
+
+func TestWindows(t *testing.T) {
+	b := Of(0, 2, 3, 63)
+	got := b.Windows(3)
+	if len(got) != 62 {
+		t.Fatalf("Bits(%s).Windows(3) returned %d windows, want 62", b, len(got))
+	}
+	want := []uint64{0x5, 0x6, 0x3, 0x1, 0x0}
+	if !reflect.DeepEqual(got[:5], want) {
+		t.Fatalf("Bits(%s).Windows(3)[:5] returned %v, want %v", b, got[:5], want)
+	}
+	if got[61] != 0x4 {
+		t.Fatalf("Bits(%s).Windows(3)[61] returned %#x, want 0x4", b, got[61])
+	}
+	if got := b.Windows(64); !reflect.DeepEqual(got, []uint64{uint64(b)}) {
+		t.Fatalf("Bits(%s).Windows(64) returned %v, want [%d]", b, got, uint64(b))
+	}
+	if got := b.Windows(1); len(got) != 64 || got[2] != 1 || got[1] != 0 {
+		t.Fatalf("Bits(%s).Windows(1) returned %v", b, got)
+	}
+	for _, w := range []int{-1, 0, 65} {
+		if got := b.Windows(w); got != nil {
+			t.Fatalf("Bits(%s).Windows(%d) returned %v, want nil", b, w, got)
+		}
+	}
+}
+
+func TestIndexPattern(t *testing.T) {
+	b := Of(0, 2, 3, 10, 12, 13, 63)
+	tests := []struct {
+		pattern Bits
+		width   int
+		want    int
+	}{
+		{Of(0, 1), 2, 2},
+		{Of(0, 2, 3), 4, 0},
+		{Of(0, 2, 3), 5, 0},
+		{Of(2, 4, 5), 6, 8},
+		{Of(0, 2, 3, 9), 4, 0}, // bits of pattern above width are ignored
+		{Of(0, 1, 2), 3, -1},
+		{0, 3, 4},
+		{Of(9), 10, 54},
+		{b, 64, 0},
+		{Of(2, 3), 64, -1},
+		{Of(0), 0, -1},
+		{Of(0), 65, -1},
+	}
+	for _, tt := range tests {
+		if got := b.IndexPattern(tt.pattern, tt.width); got != tt.want {
+			t.Fatalf("Bits(%s).IndexPattern(%s, %d) returned %d, want %d", b, tt.pattern, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestGaps64(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{Of(7), 0},
+		{Of(1, 5), Of(2, 3, 4)},
+		{Of(3, 4, 5), 0},
+		{Of(0, 63), Range(1, 62, 1)},
+		{Of(0, 2, 3, 6, 63), Range(7, 62, 1).Set(1).Set(4).Set(5)},
+		{^Bits(0), 0},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Gaps64(); got != tt.want {
+			t.Fatalf("Bits(%s).Gaps64() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRingSlots(t *testing.T) {
+	tests := []struct {
+		head, count int
+		want        Bits
+	}{
+		{0, 0, 0},
+		{5, 0, 0},
+		{5, -1, 0},
+		{0, 3, Of(0, 1, 2)},
+		{10, 2, Of(10, 11)},
+		{62, 4, Of(62, 63, 0, 1)},
+		{63, 1, Of(63)},
+		{64 + 3, 2, Of(3, 4)},
+		{-1, 2, Of(63, 0)},
+		{17, 64, ^Bits(0)},
+		{17, 100, ^Bits(0)},
+	}
+	for _, tt := range tests {
+		if got := RingSlots(tt.head, tt.count); got != tt.want {
+			t.Fatalf("RingSlots(%d, %d) returned %s, want %s", tt.head, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestEquivalence(t *testing.T) {
+	tests := []struct {
+		a, b Bits
+	}{
+		{0, 0},
+		{0, ^Bits(0)},
+		{Of(1, 5, 63), Of(1, 5, 63)},
+		{Of(1, 5, 63), Of(2, 5)},
+		{Range(0, 63, 2), Range(0, 31, 1)},
+	}
+	for _, tt := range tests {
+		got := tt.a.Equivalence(tt.b)
+		if want := ^(tt.a ^ tt.b); got != want {
+			t.Fatalf("Bits(%s).Equivalence(%s) returned %s, want %s", tt.a, tt.b, got, want)
+		}
+		if want := 64 - (tt.a ^ tt.b).Count(); got.Count() != want {
+			t.Fatalf("Bits(%s).Equivalence(%s) has %d bits set, want %d", tt.a, tt.b, got.Count(), want)
+		}
+	}
+	if got, want := Of(1, 5, 63).Equivalence(Of(2, 5)), ^Of(1, 2, 63); got != want {
+		t.Fatalf("Equivalence returned %s, want %s", got, want)
+	}
+}
+
+func TestFromBools(t *testing.T) {
+	if got := FromBools(nil); got != 0 {
+		t.Fatalf("FromBools(nil) returned %s, want empty", got)
+	}
+	if got, want := FromBools([]bool{true, false, true}), Of(0, 2); got != want {
+		t.Fatalf("FromBools([true false true]) returned %s, want %s", got, want)
+	}
+	bs := make([]bool, 70)
+	for i := range bs {
+		bs[i] = i%3 == 0
+	}
+	if got, want := FromBools(bs), Range(0, 63, 3); got != want {
+		t.Fatalf("FromBools(%v) returned %s, want %s", bs, got, want)
+	}
+}
+
+func TestChunks(t *testing.T) {
+	b := Of(0, 1, 7, 8, 20, 31, 32, 50, 63)
+	for _, n := range []int{1, 2, 3, 5, 7, 8, 63, 64, 100} {
+		chunks := b.Chunks(n)
+		wantLen := n
+		if wantLen > 64 {
+			wantLen = 64
+		}
+		if len(chunks) != wantLen {
+			t.Fatalf("Bits(%s).Chunks(%d) returned %d chunks, want %d", b, n, len(chunks), wantLen)
+		}
+		var union Bits
+		prevMost := -1
+		for i, c := range chunks {
+			if union&c != 0 {
+				t.Fatalf("Bits(%s).Chunks(%d)[%d] = %s overlaps earlier chunks", b, n, i, c)
+			}
+			if c != 0 && c.Least() <= prevMost {
+				t.Fatalf("Bits(%s).Chunks(%d) is not in ascending order", b, n)
+			}
+			if c != 0 {
+				prevMost = c.Most()
+			}
+			union |= c
+		}
+		if union != b {
+			t.Fatalf("union of Bits(%s).Chunks(%d) is %s", b, n, union)
+		}
+	}
+	if got, want := b.Chunks(3), []Bits{Of(0, 1, 7, 8, 20), Of(31, 32), Of(50, 63)}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bits(%s).Chunks(3) returned %v, want %v", b, got, want)
+	}
+	if got := b.Chunks(0); got != nil {
+		t.Fatalf("Bits(%s).Chunks(0) returned %v, want nil", b, got)
+	}
+}
+
+func TestFirstLastWithCount(t *testing.T) {
+	for k := 0; k <= 64; k++ {
+		first, last := FirstWithCount(k), LastWithCount(k)
+		if first.Count() != k || last.Count() != k {
+			t.Fatalf("FirstWithCount(%d) = %s, LastWithCount(%d) = %s; want %d bits set", k, first, k, last, k)
+		}
+		if k > 0 && (first.Least() != 0 || last.Most() != 63) {
+			t.Fatalf("FirstWithCount(%d) = %s, LastWithCount(%d) = %s", k, first, k, last)
+		}
+		if first > last {
+			t.Fatalf("FirstWithCount(%d) > LastWithCount(%d)", k, k)
+		}
+	}
+	if got := FirstWithCount(0); !got.Empty() {
+		t.Fatalf("FirstWithCount(0) returned %s, want empty", got)
+	}
+	if got := FirstWithCount(64); got != ^Bits(0) {
+		t.Fatalf("FirstWithCount(64) returned %s, want full", got)
+	}
+	if got, want := LastWithCount(2), Of(62, 63); got != want {
+		t.Fatalf("LastWithCount(2) returned %s, want %s", got, want)
+	}
+	if got := LastWithCount(-1); got != 0 {
+		t.Fatalf("LastWithCount(-1) returned %s, want empty", got)
+	}
+	if got := LastWithCount(65); got != ^Bits(0) {
+		t.Fatalf("LastWithCount(65) returned %s, want full", got)
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	bs := []Bits{Of(0, 1, 2, 63), Of(1, 2, 3), Of(2, 3, 4, 63)}
+	tests := []struct {
+		quorum int
+		want   Bits
+	}{
+		{1, bs[0] | bs[1] | bs[2]},
+		{2, Of(1, 2, 3, 63)},
+		{3, bs[0] & bs[1] & bs[2]},
+		{4, 0},
+		{0, ^Bits(0)},
+	}
+	for _, tt := range tests {
+		if got := Threshold(bs, tt.quorum); got != tt.want {
+			t.Fatalf("Threshold(%v, %d) returned %s, want %s", bs, tt.quorum, got, tt.want)
+		}
+	}
+	if got := Threshold(nil, 1); got != 0 {
+		t.Fatalf("Threshold(nil, 1) returned %s, want empty", got)
+	}
+}
+
+func TestRankVector(t *testing.T) {
+	for _, b := range []Bits{0, ^Bits(0), Of(0), Of(63), Of(1, 5, 9, 40, 62)} {
+		ranks := b.RankVector()
+		for i, r := range ranks {
+			if want := (b & Thermometer(i)).Count(); r != want {
+				t.Fatalf("Bits(%s).RankVector()[%d] is %d, want %d", b, i, r, want)
+			}
+			if i > 0 && r < ranks[i-1] {
+				t.Fatalf("Bits(%s).RankVector() is not monotonic at %d: %v", b, i, ranks)
+			}
+		}
+	}
+	b := Of(1, 5, 9)
+	it := b.Iter()
+	ranks := b.RankVector()
+	for x, i := it.Next(), 0; x >= 0; x, i = it.Next(), i+1 {
+		if ranks[x] != i {
+			t.Fatalf("Bits(%s).RankVector()[%d] is %d, want %d", b, x, ranks[x], i)
+		}
+	}
+}
+
+func TestOneHotValue(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want int
+		ok   bool
+	}{
+		{0, 0, false},
+		{Of(0), 0, true},
+		{Of(17), 17, true},
+		{Of(63), 63, true},
+		{Of(3, 4), 0, false},
+		{^Bits(0), 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := tt.b.OneHotValue(); got != tt.want || ok != tt.ok {
+			t.Fatalf("Bits(%s).OneHotValue() returned (%d, %v), want (%d, %v)", tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDumpState(t *testing.T) {
+	b := Of(0, 2, 63)
+	lines := strings.Split(strings.TrimSuffix(b.DumpState(), "\n"), "\n")
+	if len(lines) != 64 {
+		t.Fatalf("Bits(%s).DumpState() returned %d lines, want 64", b, len(lines))
+	}
+	for i, line := range lines {
+		want := "bit " + strconv.Itoa(i) + ": clear"
+		if b.Test(i) {
+			want = "bit " + strconv.Itoa(i) + ": set"
+		}
+		if line != want {
+			t.Fatalf("Bits(%s).DumpState() line %d is %q, want %q", b, i, line, want)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	rule90 := func(left, center, right bool) bool { return left != right }
+	gens := []Bits{
+		Of(32),
+		Of(31, 33),
+		Of(30, 34),
+		Of(29, 31, 33, 35),
+		Of(28, 36),
+	}
+	for i := 1; i < len(gens); i++ {
+		if got := gens[i-1].Step(rule90); got != gens[i] {
+			t.Fatalf("Bits(%s).Step(rule90) returned %s, want %s", gens[i-1], got, gens[i])
+		}
+	}
+
+	// Out-of-range neighbors are clear.
+	if got, want := Of(0, 63).Step(rule90), Of(1, 62); got != want {
+		t.Fatalf("Bits(0 63).Step(rule90) returned %s, want %s", got, want)
+	}
+
+	// Verify the orientation of left and right.
+	shiftLeft := func(left, center, right bool) bool { return right }
+	if got, want := Of(0, 5).Step(shiftLeft), Of(1, 6); got != want {
+		t.Fatalf("Bits(0 5).Step(shiftLeft) returned %s, want %s", got, want)
+	}
+	identity := func(left, center, right bool) bool { return center }
+	if b := Of(1, 7, 63); b.Step(identity) != b {
+		t.Fatalf("Bits(%s).Step(identity) returned %s", b, b.Step(identity))
+	}
+}
+
+func TestByteCounts(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want [8]int
+	}{
+		{0, [8]int{}},
+		{^Bits(0), [8]int{8, 8, 8, 8, 8, 8, 8, 8}},
+		{Of(0, 7, 8, 20, 21, 22, 63), [8]int{2, 1, 3, 0, 0, 0, 0, 1}},
+	}
+	for _, tt := range tests {
+		got := tt.b.ByteCounts()
+		if got != tt.want {
+			t.Fatalf("Bits(%s).ByteCounts() returned %v, want %v", tt.b, got, tt.want)
+		}
+		sum := 0
+		for _, n := range got {
+			sum += n
+		}
+		if sum != tt.b.Count() {
+			t.Fatalf("Bits(%s).ByteCounts() sums to %d, want %d", tt.b, sum, tt.b.Count())
+		}
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	tests := []struct {
+		b     Bits
+		limit int
+		want  string
+	}{
+		{0, 3, ""},
+		{0, 0, ""},
+		{Of(1, 3, 5), 3, "1,3,5"},
+		{Of(1, 3, 5), 10, "1,3,5"},
+		{Of(1, 3, 5, 7), 2, "1,3…(+2 more)"},
+		{Of(1, 3, 5), 1, "1…(+2 more)"},
+		{Of(1, 3, 5), 0, "…(+3 more)"},
+		{Of(1, 3, 5), -1, "…(+3 more)"},
+		{^Bits(0), 4, "0,1,2,3…(+60 more)"},
+	}
+	for _, tt := range tests {
+		if got := tt.b.SummaryString(tt.limit); got != tt.want {
+			t.Fatalf("Bits(%s).SummaryString(%d) returned %q, want %q", tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRunLengths(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want []int
+	}{
+		{0, []int{64}},
+		{^Bits(0), []int{0, 64}},
+		{Of(0), []int{0, 1, 63}},
+		{Of(63), []int{63, 1}},
+		{Of(1, 2, 5, 6, 9), []int{1, 2, 2, 2, 2, 1, 54}},
+		{Of(0, 1, 62, 63), []int{0, 2, 60, 2}},
+	}
+	for _, tt := range tests {
+		got := tt.b.RunLengths()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Bits(%s).RunLengths() returned %v, want %v", tt.b, got, tt.want)
+		}
+		b, err := FromRunLengths(got)
+		if err != nil {
+			t.Fatalf("FromRunLengths(%v) returned error: %v", got, err)
+		}
+		if b != tt.b {
+			t.Fatalf("FromRunLengths(%v) returned %s, want %s", got, b, tt.b)
+		}
+	}
+
+	if b, err := FromRunLengths([]int{2, 3}); err != nil || b != Of(2, 3, 4) {
+		t.Fatalf("FromRunLengths([2 3]) returned (%s, %v), want (2 3 4, nil)", b, err)
+	}
+	if b, err := FromRunLengths(nil); err != nil || b != 0 {
+		t.Fatalf("FromRunLengths(nil) returned (%s, %v), want empty", b, err)
+	}
+	for _, runs := range [][]int{{65}, {60, 4, 1}, {1, -1}} {
+		if b, err := FromRunLengths(runs); err == nil {
+			t.Fatalf("FromRunLengths(%v) returned %s, want error", runs, b)
+		}
+	}
+}
+
+func TestTick(t *testing.T) {
+	tests := []struct {
+		b, expired, remaining Bits
+		by                    int
+	}{
+		{0, 0, 0, 3},
+		{Of(0, 3, 5, 63), Of(0), Of(1, 3, 61), 2},
+		{Of(0, 3, 5, 63), 0, Of(0, 3, 5, 63), 0},
+		{Of(0, 3, 5, 63), 0, Of(0, 3, 5, 63), -1},
+		{Of(0, 3, 5, 63), Of(0, 3, 5), Of(0), 63},
+		{Of(0, 3, 5, 63), Of(0, 3, 5, 63), 0, 64},
+		{Of(0, 3, 5, 63), Of(0, 3, 5, 63), 0, 100},
+	}
+	for _, tt := range tests {
+		expired, remaining := tt.b.Tick(tt.by)
+		if expired != tt.expired || remaining != tt.remaining {
+			t.Fatalf("Bits(%s).Tick(%d) returned (%s, %s), want (%s, %s)",
+				tt.b, tt.by, expired, remaining, tt.expired, tt.remaining)
+		}
+	}
+
+	// Ticking one slot at a time expires every timer exactly once, in order.
+	b := Of(0, 3, 5, 40, 63)
+	var fired []int
+	for tick := 0; tick < 64; tick++ {
+		expired, remaining := b.Tick(1)
+		if expired.Count()+remaining.Count() != b.Count() {
+			t.Fatalf("Bits(%s).Tick(1) returned (%s, %s), which changes the total count", b, expired, remaining)
+		}
+		if !expired.Empty() {
+			fired = append(fired, tick)
+		}
+		b = remaining
+	}
+	if want := []int{0, 3, 5, 40, 63}; !reflect.DeepEqual(fired, want) {
+		t.Fatalf("timers fired at ticks %v, want %v", fired, want)
+	}
+}
+
+func TestCoverBytes(t *testing.T) {
+	intsOf := func(vs []byte) []int {
+		xs := make([]int, len(vs))
+		for i, v := range vs {
+			xs[i] = int(v)
+		}
+		return xs
+	}
+	for _, vs := range [][]byte{nil, {0}, {63, 1, 1, 5}, {0, 200, 64, 63}} {
+		if got, want := CoverBytes(vs), Of(intsOf(vs)...); got != want {
+			t.Fatalf("CoverBytes(%v) returned %s, want %s", vs, got, want)
+		}
+	}
+	if got, want := CoverBytes([]byte{3, 200}), Of(3); got != want {
+		t.Fatalf("CoverBytes([3 200]) returned %s, want %s", got, want)
+	}
+}
+
+func TestSymmetryEquivalent(t *testing.T) {
+	b := Of(1, 2, 7, 30)
+	rot := Bits(bits.RotateLeft64(uint64(b), 45))
+	ref := Bits(bits.Reverse64(uint64(rot)))
+	for _, other := range []Bits{b, rot, ref, Bits(bits.RotateLeft64(uint64(ref), -3))} {
+		if !b.SymmetryEquivalent(other) {
+			t.Fatalf("Bits(%s).SymmetryEquivalent(%s) returned false", b, other)
+		}
+	}
+	for _, other := range []Bits{0, Of(1, 2, 7), Of(1, 2, 7, 31), ^b} {
+		if b.SymmetryEquivalent(other) {
+			t.Fatalf("Bits(%s).SymmetryEquivalent(%s) returned true", b, other)
+		}
+	}
+}
+
+func TestRandomClear(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	b := ^Of(0, 17, 63)
+	var seen Bits
+	for i := 0; i < 1000; i++ {
+		x := b.RandomClear(r)
+		if x < 0 || x > 63 || b.Test(x) {
+			t.Fatalf("Bits(%s).RandomClear returned %d, which is not clear", b, x)
+		}
+		seen = seen.Set(x)
+	}
+	if seen != ^b {
+		t.Fatalf("Bits(%s).RandomClear only returned %s in 1000 calls", b, seen)
+	}
+	if x := Bits(0).RandomClear(r); x < 0 || x > 63 {
+		t.Fatalf("Bits(0).RandomClear returned %d", x)
+	}
+	if x := (^Bits(0)).RandomClear(r); x != -1 {
+		t.Fatalf("full Bits.RandomClear returned %d, want -1", x)
+	}
+}
+
+func TestFillLowestGap(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{Of(5), Of(5)},
+		{Of(0, 2), Of(0, 1, 2)},
+		{Of(3, 4, 5), Of(3, 4, 5)},
+		{Of(1, 2, 5, 9), Of(1, 2, 3, 5, 9)},
+		{Of(0, 63), Of(0, 1, 63)},
+		{^Bits(0), ^Bits(0)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.FillLowestGap(); got != tt.want {
+			t.Fatalf("Bits(%s).FillLowestGap() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want float64
+		ok   bool
+	}{
+		{0, 0, false},
+		{Of(0, 4), 2, true},
+		{Of(7), 7, true},
+		{Of(0, 1, 63), 64.0 / 3, true},
+		{^Bits(0), 31.5, true},
+	}
+	for _, tt := range tests {
+		if got, ok := tt.b.Centroid(); got != tt.want || ok != tt.ok {
+			t.Fatalf("Bits(%s).Centroid() returned (%v, %v), want (%v, %v)", tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLongestRun(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want int
+	}{
+		{0, 0},
+		{Of(5), 1},
+		{Of(1, 2, 5, 6, 7, 9), 3},
+		{Range(0, 63, 2), 1},
+		{Range(40, 63, 1).Set(0), 24},
+		{^Bits(0), 64},
+	}
+	for _, tt := range tests {
+		if got := tt.b.LongestRun(); got != tt.want {
+			t.Fatalf("Bits(%s).LongestRun() returned %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLongestCommonRun(t *testing.T) {
+	tests := []struct {
+		a, b Bits
+		want int
+	}{
+		{0, 0, 0},
+		{Range(0, 9, 1), Range(10, 19, 1), 0},
+		{Range(0, 9, 1), Range(5, 19, 1), 5},
+		{Range(0, 9, 1).Unset(3), Range(0, 19, 1), 6},
+		{Of(1, 2, 3, 10, 11), Of(2, 3, 4, 10, 11, 12), 2},
+		{^Bits(0), ^Bits(0), 64},
+	}
+	for _, tt := range tests {
+		if got := tt.a.LongestCommonRun(tt.b); got != tt.want {
+			t.Fatalf("Bits(%s).LongestCommonRun(%s) returned %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	b := Of(1, 5, 63)
+	s := b.Snapshot()
+	b = b.Set(3).Unset(5).Unset(63)
+	if b == Of(1, 5, 63) {
+		t.Fatalf("mutations had no effect")
+	}
+	if got, want := b.Restore(s), Of(1, 5, 63); got != want {
+		t.Fatalf("Bits(%s).Restore(snapshot) returned %s, want %s", b, got, want)
+	}
+	if got := Bits(0).Restore(Bits(0).Snapshot()); got != 0 {
+		t.Fatalf("restoring an empty snapshot returned %s", got)
+	}
+}
+
+func TestReverseBits8(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{^Bits(0), ^Bits(0)},
+		{Of(0), Of(7)},
+		{Of(7), Of(0)},
+		{Of(0, 8, 16, 24, 32, 40, 48, 56), Of(7, 15, 23, 31, 39, 47, 55, 63)},
+		{Of(1, 10, 63), Of(6, 13, 56)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.ReverseBits8(); got != tt.want {
+			t.Fatalf("Bits(%s).ReverseBits8() returned %s, want %s", tt.b, got, tt.want)
+		}
+		if got := tt.b.ReverseBits8().ReverseBits8(); got != tt.b {
+			t.Fatalf("Bits(%s).ReverseBits8().ReverseBits8() returned %s", tt.b, got)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	for _, b := range []Bits{0, Of(0), Of(1, 5, 63), ^Bits(0)} {
+		m := b.ToMap()
+		if len(m) != b.Count() {
+			t.Fatalf("Bits(%s).ToMap() returned %d entries, want %d", b, len(m), b.Count())
+		}
+		if got := FromMap(m); got != b {
+			t.Fatalf("FromMap(Bits(%s).ToMap()) returned %s", b, got)
+		}
+	}
+	if got, want := Of(2, 4).ToMap(), map[int]bool{2: true, 4: true}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bits(2 4).ToMap() returned %v, want %v", got, want)
+	}
+	m := map[int]bool{1: true, 2: false, 3: true, -1: true, 64: true}
+	if got, want := FromMap(m), Of(1, 3); got != want {
+		t.Fatalf("FromMap(%v) returned %s, want %s", m, got, want)
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	tests := []struct {
+		name                         string
+		a, b                         Bits
+		union, intersect, diff, symm Bits
+	}{
+		{"both empty", 0, 0, 0, 0, 0, 0},
+		{"empty left", 0, Of(1, 63), Of(1, 63), 0, 0, Of(1, 63)},
+		{"empty right", Of(1, 63), 0, Of(1, 63), 0, Of(1, 63), Of(1, 63)},
+		{"disjoint", Of(0, 2), Of(1, 3), Of(0, 1, 2, 3), 0, Of(0, 2), Of(0, 1, 2, 3)},
+		{"overlapping", Of(0, 1, 2), Of(2, 3, 63), Of(0, 1, 2, 3, 63), Of(2), Of(0, 1), Of(0, 1, 3, 63)},
+		{"equal", Of(4, 5), Of(4, 5), Of(4, 5), Of(4, 5), 0, 0},
+		{"nested", Of(4), Of(4, 5), Of(4, 5), Of(4), 0, Of(5)},
+		{"full", ^Bits(0), Of(7), ^Bits(0), Of(7), ^Of(7), ^Of(7)},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Union(tt.b); got != tt.union {
+			t.Fatalf("%s: Bits(%s).Union(%s) returned %s, want %s", tt.name, tt.a, tt.b, got, tt.union)
+		}
+		if got := tt.a.Intersect(tt.b); got != tt.intersect {
+			t.Fatalf("%s: Bits(%s).Intersect(%s) returned %s, want %s", tt.name, tt.a, tt.b, got, tt.intersect)
+		}
+		if got := tt.a.Difference(tt.b); got != tt.diff {
+			t.Fatalf("%s: Bits(%s).Difference(%s) returned %s, want %s", tt.name, tt.a, tt.b, got, tt.diff)
+		}
+		if got := tt.a.SymmetricDifference(tt.b); got != tt.symm {
+			t.Fatalf("%s: Bits(%s).SymmetricDifference(%s) returned %s, want %s", tt.name, tt.a, tt.b, got, tt.symm)
+		}
+	}
+}
+
+func TestComplement(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, ^Bits(0)},
+		{^Bits(0), 0},
+		{Of(0, 1), Range(2, 63, 1)},
+		{Range(0, 63, 2), Range(1, 63, 2)},
+	}
+	for _, tt := range tests {
+		got := tt.b.Complement()
+		if got != tt.want {
+			t.Fatalf("Bits(%s).Complement() returned %s, want %s", tt.b, got, tt.want)
+		}
+		if got.Count() != 64-tt.b.Count() {
+			t.Fatalf("Bits(%s).Complement() has %d bits set, want %d", tt.b, got.Count(), 64-tt.b.Count())
+		}
+	}
+}
+
+func TestGradient(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{Of(2, 3, 4), Of(2, 5)},
+		{Of(0), Of(0, 1)},
+		{Of(1, 2, 6), Of(1, 3, 6, 7)},
+		{Of(62, 63), Of(62)},
+		{^Bits(0), Of(0)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Gradient(); got != tt.want {
+			t.Fatalf("Bits(%s).Gradient() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSubsetSupersetDisjoint(t *testing.T) {
+	tests := []struct {
+		name                       string
+		a, b                       Bits
+		subset, superset, disjoint bool
+	}{
+		{"both empty", 0, 0, true, true, true},
+		{"empty left", 0, Of(1, 2), true, false, true},
+		{"empty right", Of(1, 2), 0, false, true, true},
+		{"equal", Of(1, 2, 63), Of(1, 2, 63), true, true, false},
+		{"overlapping", Of(1, 2, 3), Of(3, 4), false, false, false},
+		{"disjoint", Of(0, 2), Of(1, 63), false, false, true},
+		{"nested", Of(2), Of(1, 2, 3), true, false, false},
+		{"nesting", Of(1, 2, 3), Of(2), false, true, false},
+		{"full", ^Bits(0), Of(5, 63), false, true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Subset(tt.b); got != tt.subset {
+			t.Fatalf("%s: Bits(%s).Subset(%s) returned %v, want %v", tt.name, tt.a, tt.b, got, tt.subset)
+		}
+		if got := tt.a.Superset(tt.b); got != tt.superset {
+			t.Fatalf("%s: Bits(%s).Superset(%s) returned %v, want %v", tt.name, tt.a, tt.b, got, tt.superset)
+		}
+		if got := tt.a.Disjoint(tt.b); got != tt.disjoint {
+			t.Fatalf("%s: Bits(%s).Disjoint(%s) returned %v, want %v", tt.name, tt.a, tt.b, got, tt.disjoint)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		b, allowed, clean, dropped Bits
+	}{
+		{0, 0, 0, 0},
+		{Of(1, 2), 0, 0, Of(1, 2)},
+		{Of(1, 2), ^Bits(0), Of(1, 2), 0},
+		{Of(1, 2, 40, 63), Range(0, 31, 1), Of(1, 2), Of(40, 63)},
+	}
+	for _, tt := range tests {
+		clean, dropped := tt.b.Sanitize(tt.allowed)
+		if clean != tt.clean || dropped != tt.dropped {
+			t.Fatalf("Bits(%s).Sanitize(%s) returned (%s, %s), want (%s, %s)",
+				tt.b, tt.allowed, clean, dropped, tt.clean, tt.dropped)
+		}
+		if clean.Union(dropped) != tt.b || !clean.Subset(tt.allowed) || !dropped.Disjoint(tt.allowed) {
+			t.Fatalf("Bits(%s).Sanitize(%s) returned inconsistent (%s, %s)", tt.b, tt.allowed, clean, dropped)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	for _, b := range []Bits{0, Of(0), Of(63), Of(0, 2, 4, 5, 12, 63), ^Bits(0)} {
+		var want, got []int
+		it := b.Iter()
+		for x := it.Next(); x >= 0; x = it.Next() {
+			want = append(want, x)
+		}
+		for x := range b.All() {
+			got = append(got, x)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ranging over Bits(%s).All() returned %v, want %v", b, got, want)
+		}
+	}
+
+	b := Of(1, 3, 5, 7, 9)
+	var got []int
+	for x := range b.All() {
+		if x > 5 {
+			break
+		}
+		got = append(got, x)
+	}
+	if want := []int{1, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranging over Bits(%s).All() with break returned %v, want %v", b, got, want)
+	}
+}
+
+func TestReverseAll(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want []int
+	}{
+		{0, nil},
+		{Of(0), []int{0}},
+		{Of(17), []int{17}},
+		{Of(63), []int{63}},
+		{Of(0, 2, 4, 5, 12, 63), []int{63, 12, 5, 4, 2, 0}},
+	}
+	for _, tt := range tests {
+		var got []int
+		for x := range tt.b.ReverseAll() {
+			got = append(got, x)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("ranging over Bits(%s).ReverseAll() returned %v, want %v", tt.b, got, tt.want)
+		}
+	}
+
+	b := Of(1, 3, 5, 7, 63)
+	var got []int
+	for x := range b.ReverseAll() {
+		if x < 5 {
+			break
+		}
+		got = append(got, x)
+	}
+	if want := []int{63, 7, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranging over Bits(%s).ReverseAll() with break returned %v, want %v", b, got, want)
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		b, want Bits
+	}{
+		{0, 0},
+		{^Bits(0), ^Bits(0)},
+		{Of(0), Of(63)},
+		{Of(63), Of(0)},
+		{Of(1, 7, 40), Of(62, 56, 23)},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Reverse(); got != tt.want {
+			t.Fatalf("Bits(%s).Reverse() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	identity := func(pos int, set bool) bool { return set }
+	negate := func(pos int, set bool) bool { return !set }
+	evens := func(pos int, set bool) bool { return set && pos%2 == 0 }
+	for _, b := range []Bits{0, ^Bits(0), Of(0, 3, 4, 63)} {
+		if got := b.Update(identity); got != b {
+			t.Fatalf("Bits(%s).Update(identity) returned %s", b, got)
+		}
+		if got := b.Update(negate); got != b.Complement() {
+			t.Fatalf("Bits(%s).Update(negate) returned %s, want %s", b, got, b.Complement())
+		}
+		if got, want := b.Update(evens), b.Intersect(Range(0, 63, 2)); got != want {
+			t.Fatalf("Bits(%s).Update(evens) returned %s, want %s", b, got, want)
+		}
+	}
+}
+
+func TestMoveDistance(t *testing.T) {
+	tests := []struct {
+		a, b Bits
+		want int
+		ok   bool
+	}{
+		{0, 0, 0, true},
+		{Of(1, 2, 3), Of(1, 2, 3), 0, true},
+		{Of(1, 2, 3), Of(1, 2, 4), 1, true},
+		{Of(1, 2, 3), Of(4, 5, 63), 3, true},
+		{Of(0, 63), Of(62, 63), 1, true},
+		{Of(1, 2), Of(1, 2, 3), 0, false},
+		{0, Of(5), 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := tt.a.MoveDistance(tt.b); got != tt.want || ok != tt.ok {
+			t.Fatalf("Bits(%s).MoveDistance(%s) returned (%d, %v), want (%d, %v)", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLocalMaxima(t *testing.T) {
+	identity := func(pos int) float64 { return float64(pos) }
+	values := map[int]float64{1: 5, 3: 2, 4: 7, 10: 7, 20: 1, 30: 9, 63: 3}
+	lookup := func(pos int) float64 { return values[pos] }
+	tests := []struct {
+		b     Bits
+		value func(int) float64
+		want  Bits
+	}{
+		{0, identity, 0},
+		{Of(7), identity, Of(7)},
+		{Of(1, 5, 9, 40), identity, Of(40)},
+		{Of(1, 3, 4, 20, 30, 63), lookup, Of(1, 4, 30)},
+		{Of(4, 10), lookup, 0},            // plateau
+		{Of(1, 3, 63), lookup, Of(1, 63)}, // endpoints
+	}
+	for _, tt := range tests {
+		if got := tt.b.LocalMaxima(tt.value); got != tt.want {
+			t.Fatalf("Bits(%s).LocalMaxima() returned %s, want %s", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAuditDiff(t *testing.T) {
+	versions := []Bits{
+		Of(0, 1, 63),
+		Of(0, 1, 2, 63), // 2 set
+		Of(0, 2, 63),    // 1 cleared
+		Of(0, 1, 2),     // 1 set, 63 cleared
+		Of(0, 1, 2),     // no change
+	}
+	var want [64]int
+	for i := range want {
+		want[i] = -1
+	}
+	want[1] = 3
+	want[2] = 1
+	want[63] = 3
+	if got := AuditDiff(versions); got != want {
+		t.Fatalf("AuditDiff(%v) returned %v, want %v", versions, got, want)
+	}
+
+	var none [64]int
+	for i := range none {
+		none[i] = -1
+	}
+	for _, vs := range [][]Bits{nil, {Of(5)}, {Of(5), Of(5)}} {
+		if got := AuditDiff(vs); got != none {
+			t.Fatalf("AuditDiff(%v) returned %v, want all -1", vs, got)
+		}
+	}
+}
+
+func TestChecked(t *testing.T) {
+	b := Of(1, 63)
+	for _, n := range []int{0, 1, 62, 63} {
+		got, err := b.SetChecked(n)
+		if err != nil || got != b.Set(n) {
+			t.Fatalf("Bits(%s).SetChecked(%d) returned (%s, %v), want (%s, nil)", b, n, got, err, b.Set(n))
+		}
+		got, err = b.UnsetChecked(n)
+		if err != nil || got != b.Unset(n) {
+			t.Fatalf("Bits(%s).UnsetChecked(%d) returned (%s, %v), want (%s, nil)", b, n, got, err, b.Unset(n))
+		}
+		ok, err := b.TestChecked(n)
+		if err != nil || ok != b.Test(n) {
+			t.Fatalf("Bits(%s).TestChecked(%d) returned (%v, %v), want (%v, nil)", b, n, ok, err, b.Test(n))
+		}
+	}
+	for _, n := range []int{-1, 64, 1000} {
+		if got, err := b.SetChecked(n); !errors.Is(err, ErrOutOfRange) || got != b {
+			t.Fatalf("Bits(%s).SetChecked(%d) returned (%s, %v), want ErrOutOfRange", b, n, got, err)
+		}
+		if got, err := b.UnsetChecked(n); !errors.Is(err, ErrOutOfRange) || got != b {
+			t.Fatalf("Bits(%s).UnsetChecked(%d) returned (%s, %v), want ErrOutOfRange", b, n, got, err)
+		}
+		if ok, err := b.TestChecked(n); !errors.Is(err, ErrOutOfRange) || ok {
+			t.Fatalf("Bits(%s).TestChecked(%d) returned (%v, %v), want ErrOutOfRange", b, n, ok, err)
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	for _, b := range []Bits{0, Of(0), Of(63), Of(0, 2, 4, 5, 12, 63), ^Bits(0)} {
+		xs := b.ToSlice()
+		if len(xs) != b.Count() {
+			t.Fatalf("Bits(%s).ToSlice() returned %v", b, xs)
+		}
+		if got := FromSlice(xs); got != b {
+			t.Fatalf("FromSlice(Bits(%s).ToSlice()) returned %s", b, got)
+		}
+	}
+	if xs := Bits(0).ToSlice(); xs != nil {
+		t.Fatalf("Bits(0).ToSlice() returned %#v, want nil", xs)
+	}
+	if got, want := Of(9, 2, 40).ToSlice(), []int{2, 9, 40}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ToSlice returned %v, want %v", got, want)
+	}
+	xs := []int{5, 5, -1, 64, 1000, 0, 63}
+	if got, want := FromSlice(xs), Of(0, 5, 63); got != want {
+		t.Fatalf("FromSlice(%v) returned %s, want %s", xs, got, want)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want []Bits
+	}{
+		{0, nil},
+		{Of(5), []Bits{Of(5)}},
+		{Of(1, 2, 5, 6, 7, 9), []Bits{Of(1, 2), Of(5, 6, 7), Of(9)}},
+		{Of(0, 62, 63), []Bits{Of(0), Of(62, 63)}},
+		{^Bits(0), []Bits{^Bits(0)}},
+	}
+	for _, tt := range tests {
+		got := tt.b.Blocks()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Bits(%s).Blocks() returned %v, want %v", tt.b, got, tt.want)
+		}
+		var union Bits
+		for _, blk := range got {
+			if blk.RunCount() != 1 || !blk.Disjoint(union) {
+				t.Fatalf("Bits(%s).Blocks() returned invalid block %s", tt.b, blk)
+			}
+			union = union.Union(blk)
+		}
+		if union != tt.b {
+			t.Fatalf("union of Bits(%s).Blocks() is %s", tt.b, union)
+		}
+	}
+}
+
+func TestFlip(t *testing.T) {
+	b := Of(1, 5, 63)
+	for n := 0; n < 64; n++ {
+		f := b.Flip(n)
+		if f.Test(n) == b.Test(n) || f.SymmetricDifference(b) != Of(n) {
+			t.Fatalf("Bits(%s).Flip(%d) returned %s", b, n, f)
+		}
+		if got := f.Flip(n); got != b {
+			t.Fatalf("Bits(%s).Flip(%d).Flip(%d) returned %s", b, n, n, got)
+		}
+	}
+
+	tests := []struct {
+		low, high int
+		want      Bits
+	}{
+		{0, 2, Of(0, 2, 5, 63)},
+		{4, 6, Of(1, 4, 6, 63)},
+		{62, 63, Of(1, 5, 62)},
+		{60, 100, Of(1, 5, 60, 61, 62)},
+		{-5, 1, Of(0, 5, 63)},
+		{7, 7, Of(1, 5, 7, 63)},
+		{7, 6, b},
+	}
+	for _, tt := range tests {
+		got := b.FlipRange(tt.low, tt.high)
+		if got != tt.want {
+			t.Fatalf("Bits(%s).FlipRange(%d, %d) returned %s, want %s", b, tt.low, tt.high, got, tt.want)
+		}
+		if got.FlipRange(tt.low, tt.high) != b {
+			t.Fatalf("Bits(%s).FlipRange(%d, %d) twice returned %s", b, tt.low, tt.high, got.FlipRange(tt.low, tt.high))
+		}
+	}
+}
+
+func TestByteParities(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want byte
+	}{
+		{0, 0},
+		{^Bits(0), 0},
+		{Of(0), 0x01},
+		{Of(0, 1), 0},
+		{Of(0, 8, 9, 16, 63), 0x85},
+	}
+	for _, tt := range tests {
+		if got := tt.b.ByteParities(); got != tt.want {
+			t.Fatalf("Bits(%s).ByteParities() returned %#x, want %#x", tt.b, got, tt.want)
+		}
+	}
+	b := Of(0, 8, 9, 16, 63)
+	for n := 0; n < 64; n++ {
+		if got, want := b.Flip(n).ByteParities(), b.ByteParities()^(1<<uint(n/8)); got != want {
+			t.Fatalf("Bits(%s).ByteParities() returned %#x, want %#x", b.Flip(n), got, want)
+		}
+	}
+}
+
+func TestNextPrev(t *testing.T) {
+	b := Of(0, 5, 6, 40, 63)
+	tests := []struct {
+		n, next, prev int
+	}{
+		{-10, 0, -1},
+		{-1, 0, -1},
+		{0, 0, 0},
+		{1, 5, 0},
+		{5, 5, 5},
+		{6, 6, 6},
+		{7, 40, 6},
+		{39, 40, 6},
+		{41, 63, 40},
+		{62, 63, 40},
+		{63, 63, 63},
+		{64, -1, 63},
+		{100, -1, 63},
+	}
+	for _, tt := range tests {
+		if got := b.Next(tt.n); got != tt.next {
+			t.Fatalf("Bits(%s).Next(%d) returned %d, want %d", b, tt.n, got, tt.next)
+		}
+		if got := b.Prev(tt.n); got != tt.prev {
+			t.Fatalf("Bits(%s).Prev(%d) returned %d, want %d", b, tt.n, got, tt.prev)
+		}
+	}
+	if got := Of(3).Next(4); got != -1 {
+		t.Fatalf("Bits(3).Next(4) returned %d, want -1", got)
+	}
+	if got := Of(3).Prev(2); got != -1 {
+		t.Fatalf("Bits(3).Prev(2) returned %d, want -1", got)
+	}
+	for _, n := range []int{0, 31, 63} {
+		if got := Bits(0).Next(n); got != -1 {
+			t.Fatalf("Bits(0).Next(%d) returned %d, want -1", n, got)
+		}
+		if got := Bits(0).Prev(n); got != -1 {
+			t.Fatalf("Bits(0).Prev(%d) returned %d, want -1", n, got)
+		}
+	}
+}
+
+func TestMaskSlice(t *testing.T) {
+	b := Of(0, 3, 8, 9, 10, 15, 16, 62, 63)
+	tests := []struct {
+		low, high   int
+		mask, slice Bits
+	}{
+		{0, 7, Of(0, 3), Of(0, 3)},
+		{8, 15, Of(8, 9, 10, 15), Of(0, 1, 2, 7)},
+		{60, 63, Of(62, 63), Of(2, 3)},
+		{60, 100, Of(62, 63), Of(2, 3)},
+		{-5, 3, Of(0, 3), Of(0, 3)},
+		{3, 3, Of(3), Of(0)},
+		{4, 4, 0, 0},
+		{10, 9, 0, 0},
+		{0, 63, b, b},
+		{64, 70, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := b.Mask(tt.low, tt.high); got != tt.mask {
+			t.Fatalf("Bits(%s).Mask(%d, %d) returned %s, want %s", b, tt.low, tt.high, got, tt.mask)
+		}
+		if got := b.Slice(tt.low, tt.high); got != tt.slice {
+			t.Fatalf("Bits(%s).Slice(%d, %d) returned %s, want %s", b, tt.low, tt.high, got, tt.slice)
+		}
+	}
+	if got := Of(9, 10).Slice(8, 15); got != 6 {
+		t.Fatalf("Bits(9 10).Slice(8, 15) returned %d, want 6", uint64(got))
+	}
+}
+
+func TestReflectAbout(t *testing.T) {
+	tests := []struct {
+		b      Bits
+		center int
+		want   Bits
+	}{
+		{0, 5, 0},
+		{Of(0, 1), 5, Of(9, 10)},
+		{Of(5), 5, Of(5)},
+		{Of(0, 10, 20), 10, Of(0, 10, 20)},
+		{Of(0, 40), 30, Of(20, 60)},
+		{Of(0, 10, 63), 40, Of(17)}, // 80 and 70 fall outside the field
+		{Of(1, 2), -1, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.b.ReflectAbout(tt.center); got != tt.want {
+			t.Fatalf("Bits(%s).ReflectAbout(%d) returned %s, want %s", tt.b, tt.center, got, tt.want)
+		}
+	}
+	if got := Of(3, 9).ReflectAbout(31).ReflectAbout(31); got != Of(3, 9) {
+		t.Fatalf("reflecting twice about 31 returned %s", got)
+	}
+	if got, want := Of(0, 63).ReflectAbout(63), Of(63); got != want {
+		t.Fatalf("Bits(0 63).ReflectAbout(63) returned %s, want %s", got, want)
+	}
+}
+
+func TestEqualCompare(t *testing.T) {
+	tests := []struct {
+		a, b  Bits
+		equal bool
+		cmp   int
+	}{
+		{0, 0, true, 0},
+		{0, Of(0), false, -1},
+		{Of(63), Of(0, 1, 2), false, +1},
+		{Of(1, 5), Of(1, 5), true, 0},
+		{Of(1, 5), Of(1, 6), false, -1},
+		{^Bits(0), Of(63), false, +1},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.equal {
+			t.Fatalf("Bits(%s).Equal(%s) returned %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+		if got := tt.a.Compare(tt.b); got != tt.cmp {
+			t.Fatalf("Bits(%s).Compare(%s) returned %d, want %d", tt.a, tt.b, got, tt.cmp)
+		}
+		if got := tt.b.Compare(tt.a); got != -tt.cmp {
+			t.Fatalf("Bits(%s).Compare(%s) returned %d, want %d", tt.b, tt.a, got, -tt.cmp)
+		}
+	}
+
+	xs := []Bits{Of(63), Of(0, 1, 2), 0, ^Bits(0), Of(5), Of(0), Of(4, 5)}
+	want := append([]Bits(nil), xs...)
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+	got := append([]Bits(nil), xs...)
+	slices.SortFunc(got, Bits.Compare)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sorting %v with Compare returned %v, want %v", xs, got, want)
+	}
+	if got[0] != 0 {
+		t.Fatalf("sorting %v did not put the empty field first", xs)
+	}
+}
+
+func TestFullClearInvert(t *testing.T) {
+	if got := Full().Count(); got != 64 {
+		t.Fatalf("Full().Count() returned %d, want 64", got)
+	}
+	if !Full().Invert().Empty() {
+		t.Fatalf("Full().Invert() returned %s, want empty", Full().Invert())
+	}
+	if got := Bits(0).Invert(); got != Full() {
+		t.Fatalf("Bits(0).Invert() returned %s, want full", got)
+	}
+	if got := Clear(); !got.Empty() || got.Invert() != Full() {
+		t.Fatalf("Clear() returned %s, want empty", got)
+	}
+	for _, b := range []Bits{0, Of(0), Of(1, 5, 63), Full()} {
+		if got := b.Invert(); got != b.Complement() {
+			t.Fatalf("Bits(%s).Invert() returned %s, want %s", b, got, b.Complement())
+		}
+	}
+	allow := Full().Unset(3).Unset(63)
+	if allow.Count() != 62 || allow.Test(3) || allow.Test(63) || !allow.Test(0) {
+		t.Fatalf("Full().Unset(3).Unset(63) returned %s", allow)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		b      Bits
+		ranges string
+		hex    string
+	}{
+		{0, "", "0x0"},
+		{Of(7), "7", "0x80"},
+		{Of(1, 2), "1-2", "0x6"},
+		{Of(1, 2, 3, 4, 5, 8, 10, 11, 12), "1-5 8 10-12", "0x1d3e"},
+		{Of(0, 2, 4), "0 2 4", "0x15"},
+		{Of(0, 1, 3, 62, 63), "0-1 3 62-63", "0xc00000000000000b"},
+		{Full(), "0-63", "0xffffffffffffffff"},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Format(Ranges); got != tt.ranges {
+			t.Fatalf("Bits(%s).Format(Ranges) returned %q, want %q", tt.b, got, tt.ranges)
+		}
+		if got := tt.b.Format(Hex); got != tt.hex {
+			t.Fatalf("Bits(%s).Format(Hex) returned %q, want %q", tt.b, got, tt.hex)
+		}
+		if got := tt.b.Format(Positions); got != tt.b.String() {
+			t.Fatalf("Bits(%s).Format(Positions) returned %q, want %q", tt.b, got, tt.b.String())
+		}
+		if got := tt.b.Format(Style(-1)); got != tt.b.String() {
+			t.Fatalf("Bits(%s).Format(Style(-1)) returned %q, want %q", tt.b, got, tt.b.String())
+		}
+	}
+}
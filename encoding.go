@@ -0,0 +1,207 @@
+package i64
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+)
+
+// MarshalText implements the encoding.TextMarshaler interface. The text form
+// of a bit field is the one returned by String, e.g. "1 3 5". Because
+// encoding/json uses this method, a bit field is encoded in JSON as a string
+// such as "1 3 5" rather than as a number.
+func (b Bits) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface. It accepts
+// the formats accepted by Parse; in particular, empty text yields the empty
+// field. If the text is invalid, it returns an error and leaves b unchanged.
+func (b *Bits) UnmarshalText(text []byte) error {
+	v, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*b = v
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. It accepts a JSON
+// string in any format accepted by UnmarshalText, as produced by MarshalText.
+// For compatibility with data encoded before Bits implemented MarshalText, it
+// also accepts a JSON number, which is taken as the value of the underlying
+// uint64. As is conventional, null leaves b unchanged. If the input is
+// invalid, UnmarshalJSON returns an error and leaves b unchanged.
+func (b *Bits) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		return b.UnmarshalText([]byte(s))
+	}
+	var n uint64
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("i64: invalid JSON bit field %s: %v", data, err)
+	}
+	*b = Bits(n)
+	return nil
+}
+
+// MakePatch returns a compact encoding of the changes needed to transform b
+// into target. Each changed position is encoded as a uvarint whose low bit is
+// 1 if the position must be set and 0 if it must be cleared, and whose
+// remaining bits hold the position. The patch is empty if b equals target.
+func (b Bits) MakePatch(target Bits) []byte {
+	diff := b ^ target
+	patch := make([]byte, 0, diff.Count())
+	var buf [binary.MaxVarintLen64]byte
+	it := diff.Iter()
+	for x := it.Next(); x >= 0; x = it.Next() {
+		op := uint64(0)
+		if target.Test(x) {
+			op = 1
+		}
+		n := binary.PutUvarint(buf[:], uint64(x)<<1|op)
+		patch = append(patch, buf[:n]...)
+	}
+	return patch
+}
+
+// ApplyPatch returns the result of applying a patch produced by MakePatch to
+// the bit field. It returns an error if the patch is truncated or refers to a
+// position outside [0, 63].
+func (b Bits) ApplyPatch(patch []byte) (Bits, error) {
+	for i := 0; i < len(patch); {
+		v, n := binary.Uvarint(patch[i:])
+		if n <= 0 {
+			return 0, fmt.Errorf("i64: corrupt patch at byte %d", i)
+		}
+		pos := v >> 1
+		if pos > 63 {
+			return 0, fmt.Errorf("i64: corrupt patch at byte %d: bit position %d out of range", i, pos)
+		}
+		if v&1 != 0 {
+			b = b.Set(int(pos))
+		} else {
+			b = b.Unset(int(pos))
+		}
+		i += n
+	}
+	return b, nil
+}
+
+const base62Digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Base62 returns the value of the bit field's underlying word in base 62,
+// using the digits 0-9, A-Z, and a-z, in that order. The result is at most 11
+// characters long and contains only URL-safe characters.
+func (b Bits) Base62() string {
+	var buf [11]byte
+	i := len(buf)
+	for x := uint64(b); ; x /= 62 {
+		i--
+		buf[i] = base62Digits[x%62]
+		if x < 62 {
+			break
+		}
+	}
+	return string(buf[i:])
+}
+
+// ParseBase62 returns the bit field encoded by s, as returned by Bits.Base62.
+// It returns an error if s is empty, contains a character that is not a
+// base-62 digit, or encodes a value that does not fit in 64 bits.
+func ParseBase62(s string) (Bits, error) {
+	if s == "" {
+		return 0, fmt.Errorf("i64: empty base-62 string")
+	}
+	var x uint64
+	for i := 0; i < len(s); i++ {
+		d := base62Value(s[i])
+		if d < 0 {
+			return 0, fmt.Errorf("i64: invalid base-62 digit %q in %q", s[i], s)
+		}
+		hi, lo := bits.Mul64(x, 62)
+		var carry uint64
+		x, carry = bits.Add64(lo, uint64(d), 0)
+		if hi != 0 || carry != 0 {
+			return 0, fmt.Errorf("i64: base-62 value %q overflows 64 bits", s)
+		}
+	}
+	return Bits(x), nil
+}
+
+// base62Value returns the value of the base-62 digit c, or -1 if c is not a
+// base-62 digit.
+func base62Value(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'A' <= c && c <= 'Z':
+		return int(c-'A') + 10
+	case 'a' <= c && c <= 'z':
+		return int(c-'a') + 36
+	}
+	return -1
+}
+
+// A codec is one of the package's serialization formats, used by
+// VerifyRoundTrip.
+type codec struct {
+	name   string
+	encode func(Bits) ([]byte, error)
+	decode func([]byte) (Bits, error)
+}
+
+var codecs = []codec{
+	{
+		name:   "String",
+		encode: func(b Bits) ([]byte, error) { return []byte(b.String()), nil },
+		decode: func(p []byte) (Bits, error) { return Parse(string(p)) },
+	},
+	{
+		name:   "MarshalText",
+		encode: func(b Bits) ([]byte, error) { return b.MarshalText() },
+		decode: func(p []byte) (b Bits, err error) { err = b.UnmarshalText(p); return b, err },
+	},
+	{
+		name:   "JSON",
+		encode: func(b Bits) ([]byte, error) { return json.Marshal(b) },
+		decode: func(p []byte) (b Bits, err error) { err = json.Unmarshal(p, &b); return b, err },
+	},
+	{
+		name:   "Base62",
+		encode: func(b Bits) ([]byte, error) { return []byte(b.Base62()), nil },
+		decode: func(p []byte) (Bits, error) { return ParseBase62(string(p)) },
+	},
+	{
+		name:   "MakePatch",
+		encode: func(b Bits) ([]byte, error) { return Bits(0).MakePatch(b), nil },
+		decode: func(p []byte) (Bits, error) { return Bits(0).ApplyPatch(p) },
+	},
+}
+
+// VerifyRoundTrip encodes b with every serialization format provided by this
+// package, decodes the result, and returns an error describing the first
+// format that fails to reproduce b. It is intended for use in tests.
+func VerifyRoundTrip(b Bits) error {
+	for _, c := range codecs {
+		p, err := c.encode(b)
+		if err != nil {
+			return fmt.Errorf("i64: %s: encoding %#x: %v", c.name, uint64(b), err)
+		}
+		got, err := c.decode(p)
+		if err != nil {
+			return fmt.Errorf("i64: %s: decoding %q: %v", c.name, p, err)
+		}
+		if got != b {
+			return fmt.Errorf("i64: %s: round trip of %#x returned %#x", c.name, uint64(b), uint64(got))
+		}
+	}
+	return nil
+}
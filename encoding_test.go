@@ -0,0 +1,157 @@
+package i64
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		b, target Bits
+		size      int
+	}{
+		{0, 0, 0},
+		{Of(1, 2, 3), Of(1, 2, 3), 0},
+		{0, Of(0, 63), 2},
+		{Of(0, 63), 0, 2},
+		{Of(1, 5, 9), Of(1, 6, 9, 40), 3},
+		{0, ^Bits(0), 64},
+	}
+	for _, tt := range tests {
+		patch := tt.b.MakePatch(tt.target)
+		if len(patch) != tt.size {
+			t.Fatalf("Bits(%s).MakePatch(%s) returned %d bytes, want %d", tt.b, tt.target, len(patch), tt.size)
+		}
+		got, err := tt.b.ApplyPatch(patch)
+		if err != nil {
+			t.Fatalf("Bits(%s).ApplyPatch(%v) returned error: %v", tt.b, patch, err)
+		}
+		if got != tt.target {
+			t.Fatalf("Bits(%s).ApplyPatch(%v) returned %s, want %s", tt.b, patch, got, tt.target)
+		}
+	}
+
+	for _, patch := range [][]byte{
+		{0x80},       // truncated uvarint
+		{0x03, 0xff}, // valid op followed by truncated uvarint
+		{0x80, 0x01}, // position 64
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, // overflow
+	} {
+		if got, err := Of(1).ApplyPatch(patch); err == nil {
+			t.Fatalf("Bits(1).ApplyPatch(%v) returned %s, want error", patch, got)
+		}
+	}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	fields := []Bits{0, ^Bits(0), Of(0), Of(63), Range(0, 63, 2), Range(1, 63, 2)}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		fields = append(fields, Bits(r.Uint64()), Bits(r.Uint64()&r.Uint64()&r.Uint64()))
+	}
+	for _, b := range fields {
+		if err := VerifyRoundTrip(b); err != nil {
+			t.Fatalf("VerifyRoundTrip(%#x) returned error: %v", uint64(b), err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	type wrapper struct {
+		Name  string
+		Flags Bits
+		Ptr   *Bits
+	}
+	b := Of(0, 5, 63)
+	in := wrapper{"x", Of(1, 3, 5), &b}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("json.Marshal(%+v) returned error: %v", in, err)
+	}
+	if want := `{"Name":"x","Flags":"1 3 5","Ptr":"0 5 63"}`; string(data) != want {
+		t.Fatalf("json.Marshal(%+v) returned %s, want %s", in, data, want)
+	}
+	var out wrapper
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("json.Unmarshal(%s) returned error: %v", data, err)
+	}
+	if out.Name != in.Name || out.Flags != in.Flags || out.Ptr == nil || *out.Ptr != *in.Ptr {
+		t.Fatalf("json.Unmarshal(%s) returned %+v, want %+v", data, out, in)
+	}
+
+	out = wrapper{Flags: Of(7)}
+	if err := json.Unmarshal([]byte(`{"Flags":""}`), &out); err != nil || out.Flags != 0 {
+		t.Fatalf(`unmarshaling "" returned (%s, %v), want empty field`, out.Flags, err)
+	}
+
+	// Data written before Bits implemented MarshalText encodes the
+	// underlying uint64 as a JSON number.
+	for _, tt := range []struct {
+		data string
+		want Bits
+	}{
+		{`{"Flags":42}`, Of(1, 3, 5)},
+		{`{"Flags":0}`, 0},
+		{`{"Flags":18446744073709551615}`, Full()},
+	} {
+		out = wrapper{Flags: Of(7)}
+		if err := json.Unmarshal([]byte(tt.data), &out); err != nil || out.Flags != tt.want {
+			t.Fatalf("json.Unmarshal(%s) returned (%s, %v), want %s", tt.data, out.Flags, err, tt.want)
+		}
+	}
+
+	out = wrapper{Flags: Of(7)}
+	if err := json.Unmarshal([]byte(`{"Flags":null}`), &out); err != nil || out.Flags != Of(7) {
+		t.Fatalf("unmarshaling null returned (%s, %v), want unchanged field", out.Flags, err)
+	}
+
+	for _, data := range []string{
+		`{"Flags":"1 x"}`,
+		`{"Flags":"64"}`,
+		`{"Flags":-1}`,
+		`{"Flags":1.5}`,
+		`{"Flags":18446744073709551616}`,
+		`{"Flags":true}`,
+		`{"Flags":[1]}`,
+	} {
+		out = wrapper{Flags: Of(7)}
+		if err := json.Unmarshal([]byte(data), &out); err == nil {
+			t.Fatalf("json.Unmarshal(%s) returned nil error", data)
+		}
+		if out.Flags != Of(7) {
+			t.Fatalf("json.Unmarshal(%s) modified the field to %s", data, out.Flags)
+		}
+	}
+}
+
+func TestBase62(t *testing.T) {
+	tests := []struct {
+		b    Bits
+		want string
+	}{
+		{0, "0"},
+		{Of(0), "1"},
+		{Bits(61), "z"},
+		{Bits(62), "10"},
+		{Bits(62*62 - 1), "zz"},
+		{^Bits(0), "LygHa16AHYF"},
+	}
+	for _, tt := range tests {
+		got := tt.b.Base62()
+		if got != tt.want {
+			t.Fatalf("Bits(%#x).Base62() returned %q, want %q", uint64(tt.b), got, tt.want)
+		}
+		if b, err := ParseBase62(got); err != nil || b != tt.b {
+			t.Fatalf("ParseBase62(%q) returned (%#x, %v), want %#x", got, uint64(b), err, uint64(tt.b))
+		}
+	}
+	if b, err := ParseBase62("000A"); err != nil || b != 10 {
+		t.Fatalf("ParseBase62(\"000A\") returned (%#x, %v), want 10", uint64(b), err)
+	}
+	for _, s := range []string{"", "abc-", "a b", "_", "é", "LygHa16AHYG", "100000000000"} {
+		if b, err := ParseBase62(s); err == nil {
+			t.Fatalf("ParseBase62(%q) returned %#x, want error", s, uint64(b))
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package i64
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Eval evaluates a boolean expression over named bit fields and returns the
+// result. The expression may contain mask names, which are looked up in masks,
+// the binary operators "|" (union), "&" (intersection), and "^" (symmetric
+// difference), the unary operator "~" (complement), and parentheses. Unary
+// "~" binds tightest, followed by "&", "^", and "|", as in C. Note that this
+// differs from Go, where "^" and "|" have the same precedence and group left
+// to right: Eval parses "A | B ^ C" as "A | (B ^ C)", not "(A | B) ^ C".
+// Whitespace is ignored. For example:
+//
+//	i64.Eval("(READ | WRITE) & ~ADMIN", masks)
+//
+// Eval returns an error if the expression is malformed or refers to a name
+// that is not in masks.
+func Eval(expr string, masks map[string]Bits) (Bits, error) {
+	p := &evalParser{s: []rune(expr), masks: masks}
+	b, err := p.parseOr()
+	if err != nil {
+		return 0, err
+	}
+	if p.skipSpace(); p.pos < len(p.s) {
+		return 0, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	return b, nil
+}
+
+// evalParser is a recursive descent parser for the expressions accepted by
+// Eval. It evaluates the expression as it parses.
+type evalParser struct {
+	s     []rune
+	pos   int
+	masks map[string]Bits
+}
+
+func (p *evalParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("i64: eval: offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *evalParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(p.s[p.pos]) {
+		p.pos++
+	}
+}
+
+// accept consumes the next non-space rune if it is op.
+func (p *evalParser) accept(op rune) bool {
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseBinary parses a left-associative chain of operands separated by op.
+func (p *evalParser) parseBinary(op rune, operand func() (Bits, error), apply func(x, y Bits) Bits) (Bits, error) {
+	x, err := operand()
+	if err != nil {
+		return 0, err
+	}
+	for p.accept(op) {
+		y, err := operand()
+		if err != nil {
+			return 0, err
+		}
+		x = apply(x, y)
+	}
+	return x, nil
+}
+
+func (p *evalParser) parseOr() (Bits, error) {
+	return p.parseBinary('|', p.parseXor, func(x, y Bits) Bits { return x | y })
+}
+
+func (p *evalParser) parseXor() (Bits, error) {
+	return p.parseBinary('^', p.parseAnd, func(x, y Bits) Bits { return x ^ y })
+}
+
+func (p *evalParser) parseAnd() (Bits, error) {
+	return p.parseBinary('&', p.parseUnary, func(x, y Bits) Bits { return x & y })
+}
+
+func (p *evalParser) parseUnary() (Bits, error) {
+	if p.accept('~') {
+		x, err := p.parseUnary()
+		return ^x, err
+	}
+	if p.accept('(') {
+		x, err := p.parseOr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(')') {
+			return 0, p.errorf("missing ')'")
+		}
+		return x, nil
+	}
+	return p.parseName()
+}
+
+func (p *evalParser) parseName() (Bits, error) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && isNameRune(p.s[p.pos], p.pos == start) {
+		p.pos++
+	}
+	if p.pos == start {
+		if p.pos == len(p.s) {
+			return 0, p.errorf("unexpected end of expression")
+		}
+		return 0, p.errorf("unexpected %q", p.s[p.pos])
+	}
+	name := string(p.s[start:p.pos])
+	b, ok := p.masks[name]
+	if !ok {
+		p.pos = start
+		return 0, p.errorf("unknown mask %q", name)
+	}
+	return b, nil
+}
+
+// isNameRune reports whether r may appear in a mask name. Names consist of
+// letters, digits, and underscores, and may not begin with a digit.
+func isNameRune(r rune, first bool) bool {
+	return r == '_' || unicode.IsLetter(r) || (!first && unicode.IsDigit(r))
+}
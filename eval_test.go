@@ -0,0 +1,64 @@
+package i64
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	masks := map[string]Bits{
+		"READ":   Of(0),
+		"WRITE":  Of(1),
+		"EXEC":   Of(2),
+		"ADMIN":  Of(1, 63),
+		"ALL":    Range(0, 63, 1),
+		"none":   0,
+		"low_4":  Range(0, 3, 1),
+		"evens2": Range(0, 63, 2),
+	}
+	tests := []struct {
+		expr string
+		want Bits
+	}{
+		{"READ", Of(0)},
+		{"READ | WRITE", Of(0, 1)},
+		{"low_4 & evens2", Of(0, 2)},
+		{"low_4 ^ ADMIN", Of(0, 2, 3, 63)},
+		{"~ALL", 0},
+		{"~none", ^Bits(0)},
+		{"~~READ", Of(0)},
+		{"(READ | WRITE) & ~ADMIN", Of(0)},
+		{"READ | WRITE & ~ADMIN", Of(0)},
+		{"READ | WRITE & ADMIN", Of(0, 1)},
+		{"(READ | WRITE) & ADMIN", Of(1)},
+		{"READ ^ WRITE | EXEC", Of(0, 1, 2)},
+		{"READ | WRITE ^ WRITE", Of(0)},
+		{"READ | low_4 ^ low_4", Of(0)}, // in Go, (READ | low_4) ^ low_4 is empty
+		{"low_4 ^ READ & evens2", Of(1, 2, 3)},
+		{"  ( ( READ|WRITE ) )\t&\nlow_4 ", Of(0, 1)},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.expr, masks)
+		if err != nil {
+			t.Fatalf("Eval(%q) returned error: %v", tt.expr, err)
+		}
+		if got != tt.want {
+			t.Fatalf("Eval(%q) returned %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{
+		"",
+		"   ",
+		"READ |",
+		"READ WRITE",
+		"(READ | WRITE",
+		"READ | WRITE)",
+		"READ + WRITE",
+		"~",
+		"UNKNOWN",
+		"READ & 2x",
+		"()",
+	} {
+		if got, err := Eval(expr, masks); err == nil {
+			t.Fatalf("Eval(%q) returned %s, want error", expr, got)
+		}
+	}
+}
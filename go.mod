@@ -1,3 +1,3 @@
 module github.com/dcowgill/i64
 
-go 1.13
+go 1.23
@@ -0,0 +1,37 @@
+package i64
+
+import "fmt"
+
+// CheckInvariants verifies that a and b satisfy the algebraic laws of the set
+// operations on bit fields, and returns an error describing the first law
+// that does not hold. It is intended to be driven by a fuzzer, and doubles as
+// documentation of the intended semantics.
+func CheckInvariants(a, b Bits) error {
+	laws := []struct {
+		name string
+		ok   bool
+	}{
+		{"union is commutative", a.Union(b) == b.Union(a)},
+		{"intersection is commutative", a.Intersect(b) == b.Intersect(a)},
+		{"symmetric difference is commutative", a.SymmetricDifference(b) == b.SymmetricDifference(a)},
+		{"De Morgan: ^(a | b) == ^a & ^b", a.Union(b).Complement() == a.Complement().Intersect(b.Complement())},
+		{"De Morgan: ^(a & b) == ^a | ^b", a.Intersect(b).Complement() == a.Complement().Union(b.Complement())},
+		{"a - b is disjoint from b", a.Difference(b).Disjoint(b)},
+		{"(a - b) | (a & b) == a", a.Difference(b).Union(a.Intersect(b)) == a},
+		{"a ^ b == (a | b) - (a & b)", a.SymmetricDifference(b) == a.Union(b).Difference(a.Intersect(b))},
+		{"a - b == a & ^b", a.Difference(b) == a.Intersect(b.Complement())},
+		{"a & b is a subset of a | b", a.Intersect(b).Subset(a.Union(b))},
+		{"|a| + |b| == |a | b| + |a & b|", a.Count()+b.Count() == a.Union(b).Count()+a.Intersect(b).Count()},
+		{"a ^ a is empty", a.SymmetricDifference(a).Empty()},
+		{"a is a subset of a | b", a.Subset(a.Union(b)) && a.Union(b).Superset(a)},
+		{"a - b is a subset of a", a.Difference(b).Subset(a)},
+		{"complement is an involution", a.Complement().Complement() == a},
+		{"complement partitions the field", a.Union(a.Complement()) == ^Bits(0) && a.Intersect(a.Complement()).Empty()},
+	}
+	for _, law := range laws {
+		if !law.ok {
+			return fmt.Errorf("i64: invariant violated for a=%#x, b=%#x: %s", uint64(a), uint64(b), law.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package i64
+
+import "testing"
+
+func FuzzCheckInvariants(f *testing.F) {
+	f.Add(uint64(0), uint64(0))
+	f.Add(uint64(0), ^uint64(0))
+	f.Add(uint64(0xff00), uint64(0x0ff0))
+	f.Add(uint64(1)<<63, uint64(1))
+	f.Fuzz(func(t *testing.T, a, b uint64) {
+		if err := CheckInvariants(Bits(a), Bits(b)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package i64
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Parse returns the bit field represented by s, which must be a list of bit
+// positions separated by whitespace, as returned by Bits.String. Positions may
+// appear in any order and may be repeated. Leading, trailing, and repeated
+// whitespace is ignored, and an empty string yields the empty field. For any
+// bit field b, Parse(b.String()) returns b.
+//
+// Parse returns an error if s contains a token that is not a decimal integer
+// or a position outside [0, 63]; in the latter case, the error wraps
+// ErrOutOfRange.
+func Parse(s string) (Bits, error) {
+	b, err := parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("i64: %w", err)
+	}
+	return b, nil
+}
+
+// parse implements Parse, but returns errors without a package prefix so that
+// callers can add their own context.
+func parse(s string) (Bits, error) {
+	var b Bits
+	for _, tok := range strings.Fields(s) {
+		n, err := strconv.Atoi(tok)
+		if err != nil {
+			return 0, fmt.Errorf("invalid bit position %q", tok)
+		}
+		if n < 0 || n > 63 {
+			return 0, fmt.Errorf("%w: %d", ErrOutOfRange, n)
+		}
+		b = b.Set(n)
+	}
+	return b, nil
+}
+
+// ParseLines reads r line by line and parses each line with Parse, returning
+// one bit field per line. A blank line yields the empty field. If a line
+// cannot be parsed, ParseLines returns the error along with its line number,
+// counting from 1.
+func ParseLines(r io.Reader) ([]Bits, error) {
+	var bs []Bits
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		b, err := parse(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("i64: line %d: %w", line, err)
+		}
+		bs = append(bs, b)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
@@ -0,0 +1,91 @@
+package i64
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Bits
+	}{
+		{"0", Of(0)},
+		{"63", Of(63)},
+		{"1 3 5", Of(1, 3, 5)},
+		{"0 2 4 5 12 63", Of(0, 2, 4, 5, 12, 63)},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.s)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.s, err)
+		}
+		if got != tt.want {
+			t.Fatalf("Parse(%q) returned %s, want %s", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"x", "1 x 3", "1,3", "-1", "64", "1 3 100", "1.5"} {
+		if got, err := Parse(s); err == nil {
+			t.Fatalf("Parse(%q) returned %s, want error", s, got)
+		}
+	}
+}
+
+func TestParseEdgeCases(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Bits
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"5 3 1", Of(1, 3, 5)},
+		{"3 3 3 1", Of(1, 3)},
+		{"  1 3\t5\n", Of(1, 3, 5)},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.s)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tt.s, err)
+		}
+		if got != tt.want {
+			t.Fatalf("Parse(%q) returned %s, want %s", tt.s, got, tt.want)
+		}
+	}
+
+	if _, err := Parse("1 64"); !errors.Is(err, ErrOutOfRange) {
+		t.Fatalf("Parse(\"1 64\") returned error %v, want ErrOutOfRange", err)
+	}
+
+	for _, b := range []Bits{0, Of(0), Of(63), Range(0, 63, 3), ^Bits(0)} {
+		if got, err := Parse(b.String()); err != nil || got != b {
+			t.Fatalf("Parse(%q) returned (%s, %v), want %s", b.String(), got, err, b)
+		}
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	input := "1 3 5\n\n0 63\n   12  \n"
+	got, err := ParseLines(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseLines(%q) returned error: %v", input, err)
+	}
+	want := []Bits{Of(1, 3, 5), 0, Of(0, 63), Of(12)}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseLines(%q) returned %v, want %v", input, got, want)
+	}
+
+	input = "1 3 5\n2\n4 x\n6\n"
+	if _, err := ParseLines(strings.NewReader(input)); err == nil {
+		t.Fatalf("ParseLines(%q) returned nil error", input)
+	} else if !strings.HasPrefix(err.Error(), "i64: line 3: ") {
+		t.Fatalf("ParseLines(%q) returned error %q, want line 3", input, err)
+	}
+
+	input = "1\n64\n"
+	if _, err := ParseLines(strings.NewReader(input)); err == nil || !strings.HasPrefix(err.Error(), "i64: line 2: ") {
+		t.Fatalf("ParseLines(%q) returned error %v, want line 2", input, err)
+	}
+}
@@ -0,0 +1,57 @@
+package i64
+
+// Pipeline applies a sequence of set operations to a bit field, recording each
+// step so that complex mask derivations can be inspected. For example:
+//
+//	p := i64.NewPipeline(base).Union(a).Intersect(b).Difference(c)
+//	mask := p.Result()
+//	fmt.Println(p.Steps()) // e.g. [Union(1 2) Intersect(2 3) Difference(3)]
+//
+// The methods of Pipeline modify the receiver and return it, so that calls can
+// be chained.
+type Pipeline struct {
+	b     Bits
+	steps []string
+}
+
+// NewPipeline returns a pipeline whose initial value is base.
+func NewPipeline(base Bits) *Pipeline {
+	return &Pipeline{b: base}
+}
+
+// Union sets the bits that are set in other.
+func (p *Pipeline) Union(other Bits) *Pipeline {
+	return p.apply("Union", other, p.b.Union(other))
+}
+
+// Intersect clears the bits that are not set in other.
+func (p *Pipeline) Intersect(other Bits) *Pipeline {
+	return p.apply("Intersect", other, p.b.Intersect(other))
+}
+
+// Difference clears the bits that are set in other.
+func (p *Pipeline) Difference(other Bits) *Pipeline {
+	return p.apply("Difference", other, p.b.Difference(other))
+}
+
+// SymmetricDifference toggles the bits that are set in other.
+func (p *Pipeline) SymmetricDifference(other Bits) *Pipeline {
+	return p.apply("SymmetricDifference", other, p.b.SymmetricDifference(other))
+}
+
+func (p *Pipeline) apply(op string, operand, result Bits) *Pipeline {
+	p.steps = append(p.steps, op+"("+operand.String()+")")
+	p.b = result
+	return p
+}
+
+// Result returns the current value of the pipeline.
+func (p *Pipeline) Result() Bits {
+	return p.b
+}
+
+// Steps returns a human-readable description of each operation applied to the
+// pipeline, in order, such as "Union(1 3)".
+func (p *Pipeline) Steps() []string {
+	return append([]string(nil), p.steps...)
+}
@@ -0,0 +1,29 @@
+package i64
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	base, a, b, c, d := Of(0, 1), Of(2, 3, 63), Of(1, 2, 3, 4), Of(3), Of(4, 5)
+	p := NewPipeline(base).Union(a).Intersect(b).Difference(c).SymmetricDifference(d)
+	if got, want := p.Result(), ((base|a)&b&^c)^d; got != want {
+		t.Fatalf("Pipeline.Result() returned %s, want %s", got, want)
+	}
+	wantSteps := []string{"Union(2 3 63)", "Intersect(1 2 3 4)", "Difference(3)", "SymmetricDifference(4 5)"}
+	if got := p.Steps(); !reflect.DeepEqual(got, wantSteps) {
+		t.Fatalf("Pipeline.Steps() returned %q, want %q", got, wantSteps)
+	}
+
+	p = NewPipeline(a)
+	if got := p.Result(); got != a {
+		t.Fatalf("NewPipeline(%s).Result() returned %s", a, got)
+	}
+	if got := p.Steps(); len(got) != 0 {
+		t.Fatalf("NewPipeline(%s).Steps() returned %q, want none", a, got)
+	}
+	if got, want := p.Union(0).Steps(), []string{"Union()"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Pipeline.Steps() returned %q, want %q", got, want)
+	}
+}